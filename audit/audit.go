@@ -0,0 +1,194 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	KindSignature = "signature"
+	KindVote      = "vote"
+	KindSent      = "sent"
+)
+
+// Record is one line of the audit log. Hash covers every other field and Prev
+// is the Hash of the line before, so an edited, dropped or reordered line
+// breaks the chain.
+type Record struct {
+	Seq  uint64
+	Time int64
+	Kind string
+
+	// signForNeo
+	SubjectHash  string `json:",omitempty"` // sha256 of the ToMerkleValue, what the neo key signs
+	Signature    string `json:",omitempty"`
+	PolyHeight   uint32 `json:",omitempty"`
+	MakeProofKey string `json:",omitempty"`
+
+	// ImportOuterTransfer
+	NeoTxHash      string `json:",omitempty"`
+	StorageKey     string `json:",omitempty"`
+	StateRootIndex uint32 `json:",omitempty"`
+
+	// the poly tx that carried the signature or vote of record RefSeq
+	RefSeq     uint64 `json:",omitempty"`
+	RefHash    string `json:",omitempty"`
+	PolyTxHash string `json:",omitempty"`
+
+	Prev string
+	Hash string
+}
+
+func (r *Record) digest() (string, error) {
+	c := *r
+	c.Hash = ""
+	data, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends records to a file and syncs each one before returning
+type Log struct {
+	mu   sync.Mutex
+	file *os.File
+	seq  uint64
+	last string
+
+	Dropped int64 // bytes of a partial last record Open cut off
+}
+
+// truncatedError is a last line without newline, left by a crash mid write
+type truncatedError struct {
+	line   int
+	offset int64
+}
+
+func (e *truncatedError) Error() string {
+	return fmt.Sprintf("line %d: truncated record", e.line)
+}
+
+// Open continues the chain of an existing file or starts a new one, a partial
+// last record is cut off since Append never returned for it
+func Open(path string) (*Log, error) {
+	l := new(Log)
+	err := walk(path, func(r *Record) error {
+		l.seq, l.last = r.Seq, r.Hash
+		return nil
+	})
+	var truncated *truncatedError
+	if errors.As(err, &truncated) {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			return nil, statErr
+		}
+		if err = os.Truncate(path, truncated.offset); err != nil {
+			return nil, fmt.Errorf("cut partial record: %v", err)
+		}
+		l.Dropped = info.Size() - truncated.offset
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if l.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) Append(r *Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = l.seq + 1
+	r.Time = time.Now().Unix()
+	r.Prev = l.last
+	hash, err := r.digest()
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err = l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write audit record %d: %v", r.Seq, err)
+	}
+	if err = l.file.Sync(); err != nil {
+		return fmt.Errorf("sync audit record %d: %v", r.Seq, err)
+	}
+	l.seq, l.last = r.Seq, r.Hash
+	return nil
+}
+
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// Verify checks the whole chain of the file and returns how many records it holds
+func Verify(path string) (count uint64, err error) {
+	err = walk(path, func(r *Record) error {
+		count++
+		return nil
+	})
+	return
+}
+
+// walk reads the file line by line, checking sequence, link and hash of each record
+func walk(path string, f func(r *Record) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var seq uint64
+	var prev string
+	var offset int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(data) == 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err == io.EOF {
+			return &truncatedError{line: line, offset: offset}
+		}
+		offset += int64(len(data))
+		r := new(Record)
+		if err = json.Unmarshal(data, r); err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if r.Seq != seq+1 {
+			return fmt.Errorf("line %d: sequence %d follows %d", line, r.Seq, seq)
+		}
+		if r.Prev != prev {
+			return fmt.Errorf("line %d: record %d does not link to the record before", line, r.Seq)
+		}
+		hash, err := r.digest()
+		if err != nil {
+			return err
+		}
+		if hash != r.Hash {
+			return fmt.Errorf("line %d: record %d hash %s, computed %s", line, r.Seq, r.Hash, hash)
+		}
+		if err = f(r); err != nil {
+			return err
+		}
+		seq, prev = r.Seq, r.Hash
+	}
+}
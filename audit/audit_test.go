@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testLog writes a vote, a signature and their sent records
+func testLog(t *testing.T) (string, [][]byte) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := func(r *Record) *Record {
+		if err := l.Append(r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	vote := add(&Record{Kind: KindVote, NeoTxHash: "0x01", StorageKey: "0102", StateRootIndex: 7})
+	add(&Record{Kind: KindSent, RefSeq: vote.Seq, RefHash: vote.Hash, PolyTxHash: "dd"})
+	signed := add(&Record{Kind: KindSignature, SubjectHash: "aa", Signature: "bb", PolyHeight: 9, MakeProofKey: "cc"})
+	add(&Record{Kind: KindSent, RefSeq: signed.Seq, RefHash: signed.Hash, PolyTxHash: "ee"})
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, bytes.SplitAfter(data, []byte("\n"))[:4]
+}
+
+func writeLines(t *testing.T, path string, lines [][]byte) {
+	if err := ioutil.WriteFile(path, bytes.Join(lines, nil), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path, lines := testLog(t)
+	for _, tc := range []struct {
+		name  string
+		lines [][]byte
+		count uint64
+		err   string
+	}{
+		{"intact", lines, 4, ""},
+		{"empty", nil, 0, ""},
+		{"edited", [][]byte{lines[0], bytes.Replace(lines[1], []byte(`"dd"`), []byte(`"de"`), 1), lines[2], lines[3]}, 0, "line 2: record 2 hash"},
+		{"dropped", [][]byte{lines[0], lines[2], lines[3]}, 0, "line 2: sequence 3 follows 1"},
+		{"reordered", [][]byte{lines[1], lines[0], lines[2], lines[3]}, 0, "line 1: sequence 2 follows 0"},
+		{"partial last line", [][]byte{lines[0], lines[1], lines[2][:10]}, 0, "line 3: truncated record"},
+		{"garbage", [][]byte{lines[0], []byte("{\n")}, 0, "line 2:"},
+	} {
+		writeLines(t, path, tc.lines)
+		count, err := Verify(path)
+		if tc.err == "" {
+			if err != nil || count != tc.count {
+				t.Fatalf("%s: %d records, %v", tc.name, count, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: got %v, want %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestOpenCutsPartialRecord(t *testing.T) {
+	path, lines := testLog(t)
+	writeLines(t, path, [][]byte{lines[0], lines[1], lines[2][:10]})
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Dropped != 10 {
+		t.Fatalf("dropped %d bytes, want 10", l.Dropped)
+	}
+	// the chain goes on from the last whole record
+	r := &Record{Kind: KindVote, NeoTxHash: "0x02"}
+	if err = l.Append(r); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	if r.Seq != 3 {
+		t.Fatalf("appended record %d, want 3", r.Seq)
+	}
+	if count, err := Verify(path); err != nil || count != 3 {
+		t.Fatalf("%d records, %v", count, err)
+	}
+}
+
+func TestOpenRefusesBrokenChain(t *testing.T) {
+	path, lines := testLog(t)
+	writeLines(t, path, [][]byte{lines[0], lines[2]})
+	if _, err := Open(path); err == nil {
+		t.Fatal("opened a log with a dropped record")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+}
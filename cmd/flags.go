@@ -20,11 +20,94 @@ var (
 		Value: config.DEFAULT_CONFIG_FILE_NAME,
 	}
 
+	SetFlag = cli.StringSliceFlag{
+		Name:  "set",
+		Usage: "Override a config field with `<Path=value>`, like NeoConfig.RpcUrlList=http://a,http://b, may repeat",
+	}
+
 	PolyPwd = cli.StringFlag{
 		Name:  "polypwd",
 		Usage: "Password for poly chain wallet",
 		Value: "",
 	}
+
+	PolyPwdFile = cli.StringFlag{
+		Name:  "polypwd-file",
+		Usage: "Read the poly wallet password from file `<path>`",
+		Value: "",
+	}
+
+	PolyPwdFd = cli.IntFlag{
+		Name:  "polypwd-fd",
+		Usage: "Read the poly wallet password from file descriptor `<fd>`",
+		Value: -1,
+	}
+
+	PolyPwdCred = cli.StringFlag{
+		Name:  "polypwd-cred",
+		Usage: "Read the poly wallet password from systemd credential `<name>`",
+		Value: "",
+	}
+
+	PolyPwdEnv = cli.StringFlag{
+		Name:  "polypwd-env",
+		Usage: "Read the poly wallet password from environment variable `<name>`",
+		Value: "NEO3VOTER_POLYPWD",
+	}
+
+	NeoPwd = cli.StringFlag{
+		Name:  "neopwd",
+		Usage: "Password for neo signing wallet",
+		Value: "",
+	}
+
+	NeoPwdFile = cli.StringFlag{
+		Name:  "neopwd-file",
+		Usage: "Read the neo wallet password from file `<path>`",
+		Value: "",
+	}
+
+	NeoPwdFd = cli.IntFlag{
+		Name:  "neopwd-fd",
+		Usage: "Read the neo wallet password from file descriptor `<fd>`",
+		Value: -1,
+	}
+
+	NeoPwdCred = cli.StringFlag{
+		Name:  "neopwd-cred",
+		Usage: "Read the neo wallet password from systemd credential `<name>`",
+		Value: "",
+	}
+
+	NeoPwdEnv = cli.StringFlag{
+		Name:  "neopwd-env",
+		Usage: "Read the neo wallet password from environment variable `<name>`",
+		Value: "NEO3VOTER_NEOPWD",
+	}
+
+	WalletFlag = cli.StringFlag{
+		Name:  "wallet",
+		Usage: "Poly wallet file `<path>`",
+		Value: "",
+	}
+
+	AccountFlag = cli.StringFlag{
+		Name:  "account",
+		Usage: "Address or label of the poly wallet `<account>`, overrides the config",
+		Value: "",
+	}
+
+	NeoWalletFlag = cli.StringFlag{
+		Name:  "neowallet",
+		Usage: "NEP-6 wallet `<path>` of a dedicated neo signing key",
+		Value: "",
+	}
+
+	SignerSocketFlag = cli.StringFlag{
+		Name:  "socket",
+		Usage: "Unix socket `<path>` the signer daemon listens on",
+		Value: "./signer.sock",
+	}
 )
 
 //GetFlagName deal with short flag, and return the flag name whether flag name have short name
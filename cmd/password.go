@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/polynetwork/neo3-voter/common"
+	"github.com/urfave/cli"
+)
+
+var (
+	PolyPwdFlags = []cli.Flag{PolyPwd, PolyPwdFile, PolyPwdFd, PolyPwdCred, PolyPwdEnv}
+	NeoPwdFlags  = []cli.Flag{NeoPwd, NeoPwdFile, NeoPwdFd, NeoPwdCred, NeoPwdEnv}
+)
+
+// PolyPasswordSource collects the poly wallet password flags, see
+// common.PasswordSource for which one wins
+func PolyPasswordSource(ctx *cli.Context) *common.PasswordSource {
+	return &common.PasswordSource{
+		Name:       "poly wallet",
+		Flag:       ctx.String(GetFlagName(PolyPwd)),
+		File:       ctx.String(GetFlagName(PolyPwdFile)),
+		Fd:         ctx.Int(GetFlagName(PolyPwdFd)),
+		Credential: ctx.String(GetFlagName(PolyPwdCred)),
+		Env:        ctx.String(GetFlagName(PolyPwdEnv)),
+	}
+}
+
+func NeoPasswordSource(ctx *cli.Context) *common.PasswordSource {
+	return &common.PasswordSource{
+		Name:       "neo wallet",
+		Flag:       ctx.String(GetFlagName(NeoPwd)),
+		File:       ctx.String(GetFlagName(NeoPwdFile)),
+		Fd:         ctx.Int(GetFlagName(NeoPwdFd)),
+		Credential: ctx.String(GetFlagName(NeoPwdCred)),
+		Env:        ctx.String(GetFlagName(NeoPwdEnv)),
+	}
+}
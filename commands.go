@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joeqian10/neo3-gogogo/crypto"
+	"github.com/joeqian10/neo3-gogogo/helper"
+	"github.com/joeqian10/neo3-gogogo/keys"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/polynetwork/neo3-voter/audit"
+	"github.com/polynetwork/neo3-voter/cmd"
+	"github.com/polynetwork/neo3-voter/common"
+	"github.com/polynetwork/neo3-voter/config"
+	"github.com/polynetwork/neo3-voter/db"
+	"github.com/polynetwork/neo3-voter/signer"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/urfave/cli"
+)
+
+var signerCommand = cli.Command{
+	Name:   "signer",
+	Usage:  "Serve signatures from a poly wallet to voters on a unix socket",
+	Action: runSigner,
+	Flags: append(append([]cli.Flag{
+		cmd.WalletFlag,
+		cmd.AccountFlag,
+		cmd.SignerSocketFlag,
+		cmd.NeoWalletFlag,
+	}, cmd.PolyPwdFlags...), cmd.NeoPwdFlags...),
+}
+
+func runSigner(ctx *cli.Context) error {
+	account, ok := common.GetAccountByPassword(sdk.NewPolySdk(), ctx.String(cmd.GetFlagName(cmd.WalletFlag)),
+		ctx.String(cmd.GetFlagName(cmd.AccountFlag)), cmd.PolyPasswordSource(ctx))
+	if !ok {
+		return fmt.Errorf("common.GetAccountByPassword error")
+	}
+	s, err := signer.NewWalletSigner(account)
+	if err != nil {
+		return err
+	}
+	if neoWallet := ctx.String(cmd.GetFlagName(cmd.NeoWalletFlag)); neoWallet != "" {
+		pwd, err := cmd.NeoPasswordSource(ctx).Read()
+		if err != nil {
+			return err
+		}
+		pair, err := signer.LoadNeoKey(neoWallet, string(pwd))
+		if err != nil {
+			return err
+		}
+		s = signer.WithNeoKey(s, pair)
+	}
+	socket := ctx.String(cmd.GetFlagName(cmd.SignerSocketFlag))
+	l, err := signer.Serve(socket, s)
+	if err != nil {
+		return err
+	}
+	Log.Infof("signer for %s listening on %s", account.Address.ToBase58(), socket)
+
+	waitToExit(nil)
+	return l.Close()
+}
+
+var accountsCommand = cli.Command{
+	Name:   "accounts",
+	Usage:  "List the poly wallet accounts with the neo key each one signs for neo with",
+	Action: listAccounts,
+	Flags: []cli.Flag{
+		cmd.WalletFlag,
+	},
+}
+
+// listAccounts needs no password, the keys come from the public part of the wallet
+func listAccounts(ctx *cli.Context) error {
+	path := ctx.String(cmd.GetFlagName(cmd.WalletFlag))
+	if path == "" {
+		if err := config.DefConfig.Init(ctx.GlobalString(cmd.GetFlagName(cmd.ConfigPathFlag)), ctx.GlobalStringSlice(cmd.GetFlagName(cmd.SetFlag))...); err != nil {
+			return err
+		}
+		path = config.DefConfig.PolyConfig.WalletFile
+	}
+	wallet, err := sdk.NewPolySdk().OpenWallet(path)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INDEX\tDEFAULT\tLABEL\tPOLY ADDRESS\tNEO PUBLIC KEY\tNEO SCRIPT HASH\tNEO ADDRESS")
+	for i := 1; i <= wallet.GetAccountCount(); i++ {
+		data, err := wallet.GetAccountDataByIndex(i)
+		if err != nil {
+			return err
+		}
+		neoPub, neoHash, neoAddress := "-", "-", "-"
+		if raw, err := hex.DecodeString(data.PubKey); err == nil {
+			if pub, err := keypair.DeserializePublicKey(raw); err == nil {
+				if point, err := signer.NeoPublicKeyOf(pub); err == nil {
+					hash := keys.PublicKeyToScriptHash(point)
+					neoPub = hex.EncodeToString(point.EncodePoint(true))
+					neoHash = "0x" + hash.String()
+					neoAddress = crypto.ScriptHashToAddress(hash, helper.DefaultAddressVersion)
+				}
+			}
+		}
+		fmt.Fprintf(w, "%d\t%v\t%s\t%s\t%s\t%s\t%s\n", i, data.IsDefault, data.Label, data.Address, neoPub, neoHash, neoAddress)
+	}
+	return w.Flush()
+}
+
+var configCommand = cli.Command{
+	Name:  "config",
+	Usage: "Inspect the voter config",
+	Subcommands: []cli.Command{
+		{
+			Name:   "check",
+			Usage:  "Report every problem in the config file",
+			Action: checkConfig,
+		},
+	},
+}
+
+func checkConfig(ctx *cli.Context) error {
+	configPath := ctx.GlobalString(cmd.GetFlagName(cmd.ConfigPathFlag))
+	if err := config.DefConfig.Init(configPath, ctx.GlobalStringSlice(cmd.GetFlagName(cmd.SetFlag))...); err != nil {
+		return err
+	}
+	if err := config.DefConfig.Validate(); err != nil {
+		return fmt.Errorf("config %s has problems:\n%v", configPath, err)
+	}
+	fmt.Printf("config %s ok\n", configPath)
+	return nil
+}
+
+var auditCommand = cli.Command{
+	Name:  "audit",
+	Usage: "Inspect the audit log of signatures and votes",
+	Subcommands: []cli.Command{
+		{
+			Name:      "verify",
+			Usage:     "Check the hash chain of the audit log, the AuditLogFile of the config by default",
+			ArgsUsage: "[file]",
+			Action:    verifyAudit,
+		},
+	},
+}
+
+func verifyAudit(ctx *cli.Context) error {
+	path := ctx.Args().First()
+	if path == "" {
+		if err := config.DefConfig.Init(ctx.GlobalString(cmd.GetFlagName(cmd.ConfigPathFlag)), ctx.GlobalStringSlice(cmd.GetFlagName(cmd.SetFlag))...); err != nil {
+			return err
+		}
+		path = config.DefConfig.AuditLogFile
+	}
+	count, err := audit.Verify(path)
+	if err != nil {
+		return fmt.Errorf("audit log %s is broken after %d records: %v", path, count, err)
+	}
+	fmt.Printf("audit log %s ok, %d records\n", path, count)
+	return nil
+}
+
+var quarantineCommand = cli.Command{
+	Name:  "quarantine",
+	Usage: "Inspect the values the sign policy refused, stop the voter first",
+	Subcommands: []cli.Command{
+		{
+			Name:   "list",
+			Usage:  "List the quarantined makeProof keys",
+			Action: listQuarantine,
+		},
+		{
+			Name:      "release",
+			Usage:     "Approve quarantined keys, the voter signs their values on its next replay without the sign policy",
+			ArgsUsage: "<makeProof key>...",
+			Action:    releaseQuarantine,
+		},
+	},
+}
+
+func openDb(ctx *cli.Context) (*db.BoltDB, error) {
+	if err := config.DefConfig.Init(ctx.GlobalString(cmd.GetFlagName(cmd.ConfigPathFlag)), ctx.GlobalStringSlice(cmd.GetFlagName(cmd.SetFlag))...); err != nil {
+		return nil, err
+	}
+	return db.NewBoltDB(config.DefConfig.BoltDbPath)
+}
+
+func listQuarantine(ctx *cli.Context) error {
+	bdb, err := openDb(ctx)
+	if err != nil {
+		return err
+	}
+	defer bdb.Close()
+	records, err := bdb.ListQuarantine()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tPOLY HEIGHT\tCREATED\tRELEASED\tREASON")
+	for _, key := range keys {
+		r := records[key]
+		fmt.Fprintf(w, "%s\t%d\t%s\t%v\t%s\n", key, r.PolyHeight, time.Unix(r.CreatedAt, 0).Format(time.RFC3339), r.Approved, r.Reason)
+	}
+	return w.Flush()
+}
+
+func releaseQuarantine(ctx *cli.Context) error {
+	if ctx.NArg() == 0 {
+		return fmt.Errorf("no makeProof key given")
+	}
+	bdb, err := openDb(ctx)
+	if err != nil {
+		return err
+	}
+	defer bdb.Close()
+	for _, key := range ctx.Args() {
+		if err = bdb.ReleaseQuarantine(key); err != nil {
+			return err
+		}
+		fmt.Printf("released %s\n", key)
+	}
+	return nil
+}
@@ -3,30 +3,33 @@ package common
 import (
 	"encoding/binary"
 	"fmt"
-	"github.com/polynetwork/poly/common/password"
 
 	rsdk "github.com/polynetwork/poly-go-sdk"
 )
 
-func GetAccountByPassword(sdk *rsdk.PolySdk, path, pwdStr string) (*rsdk.Account, bool) {
+// GetAccountByPassword opens the account given by address or label, or the
+// default account when account is empty
+func GetAccountByPassword(sdk *rsdk.PolySdk, path, account string, source *PasswordSource) (*rsdk.Account, bool) {
 	wallet, err := sdk.OpenWallet(path)
 	if err != nil {
 		fmt.Println("open wallet error:", err)
 		return nil, false
 	}
-	var pwd []byte
-	if pwdStr != "" {
-		pwd = []byte(pwdStr)
+	pwd, err := source.Read()
+	if err != nil {
+		fmt.Println("read password error: ", err)
+		return nil, false
+	}
+	var user *rsdk.Account
+	if account == "" {
+		user, err = wallet.GetDefaultAccount(pwd)
+	} else if _, err = wallet.GetAccountDataByAddress(account); err == nil {
+		user, err = wallet.GetAccountByAddress(account, pwd)
 	} else {
-		pwd, err = password.GetAccountPassword()
-		if err != nil {
-			fmt.Println("GetAccountPassword error: ", err)
-			return nil, false
-		}
+		user, err = wallet.GetAccountByLabel(account, pwd)
 	}
-	user, err := wallet.GetDefaultAccount(pwd)
 	if err != nil {
-		fmt.Println("GetDefaultAccount error:", err)
+		fmt.Printf("get account %q error: %v\n", account, err)
 		return nil, false
 	}
 	return user, true
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/polynetwork/neo3-voter/log"
+	"github.com/polynetwork/poly/common/password"
+)
+
+// PasswordSource tells where to read a wallet password from. The first source
+// set wins, in this order:
+//
+//	Flag        the password itself from the command line, visible in ps, warns
+//	File        a file holding the password, like a docker secret in /run/secrets
+//	Fd          an inherited file descriptor, negative when unused
+//	Credential  a systemd credential name under $CREDENTIALS_DIRECTORY
+//	Env         an environment variable name, the variable is unset once read
+//
+// and the interactive prompt when none is set.
+type PasswordSource struct {
+	Name       string // what the password unlocks, for messages
+	Flag       string
+	File       string
+	Fd         int
+	Credential string
+	Env        string
+}
+
+func (s *PasswordSource) Read() ([]byte, error) {
+	switch {
+	case s.Flag != "":
+		log.Log.Warnf("%s password given on the command line, it shows up in ps and shell history", s.Name)
+		return []byte(s.Flag), nil
+	case s.File != "":
+		return readPasswordFile(s.File)
+	case s.Fd >= 0:
+		// NewFile takes any number, only a stat tells whether it is open
+		f := os.NewFile(uintptr(s.Fd), fmt.Sprintf("fd%d", s.Fd))
+		defer f.Close()
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("%s password fd %d is not open: %v", s.Name, s.Fd, err)
+		}
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("read %s password from fd %d: %v", s.Name, s.Fd, err)
+		}
+		return trimPassword(data), nil
+	case s.Credential != "":
+		dir := os.Getenv("CREDENTIALS_DIRECTORY")
+		if dir == "" {
+			return nil, fmt.Errorf("%s password credential %s: CREDENTIALS_DIRECTORY is not set", s.Name, s.Credential)
+		}
+		return readPasswordFile(filepath.Join(dir, s.Credential))
+	}
+	if s.Env != "" {
+		if pwd, ok := os.LookupEnv(s.Env); ok {
+			os.Unsetenv(s.Env)
+			return []byte(pwd), nil
+		}
+	}
+	return password.GetAccountPassword()
+}
+
+func readPasswordFile(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read password file: %v", err)
+	}
+	return trimPassword(data), nil
+}
+
+// trimPassword drops the line break editors and echo leave at the end
+func trimPassword(data []byte) []byte {
+	return []byte(strings.TrimRight(string(data), "\r\n"))
+}
@@ -1,36 +1,99 @@
 package config
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
 const (
 	DEFAULT_CONFIG_FILE_NAME = "./config.json"
 	DEFAULT_LOG_LEVEL        = 2
+	DEFAULT_SHUTDOWN_TIMEOUT = 30
+	DEFAULT_HEALTH_WINDOW    = 600
+	DEFAULT_POLL_INTERVAL    = 2
+	DEFAULT_AUDIT_LOG_FILE   = "./audit.log"
+	DEFAULT_FETCH_WORKERS    = 8
+	DEFAULT_MAX_IN_FLIGHT    = 32
+	DEFAULT_TX_TIMEOUT       = 300
+	DEFAULT_MAX_RESUBMITS    = 3
+	DEFAULT_REPLAY_INTERVAL  = 600
 )
 
-//Config object used by neo-instance
+// Config object used by neo-instance
 type Config struct {
 	PolyConfig  PolyConfig
 	NeoConfig   NeoConfig
 	ForceConfig ForceConfig
+	SignPolicy  SignPolicy
 	BoltDbPath  string
+
+	AuditLogFile string // hash chained record of every signature and vote, ./audit.log when empty
+
+	ShutdownTimeout uint64 // seconds to wait for in-flight work on exit
+	HttpAddr        string // listen address of the metrics and health endpoints, disabled when empty
+	HealthWindow    uint64 // seconds a monitor loop may go without progress before it is unhealthy
+	ReplayInterval  uint64 // seconds between replays of failed events while a monitor is idle
+	Log             LogConfig
+
+	// reloaded on SIGHUP together with the rpc lists, N2PContract and Log.Level
+	NeoPollInterval  uint64 // seconds between neo polls once caught up
+	PolyPollInterval uint64 // seconds between poly polls once caught up
+}
+
+// LogConfig sets where the voter logs, zero sizes keep the defaults of 10MB,
+// 30 backups and 30 days
+type LogConfig struct {
+	Level      string // trace, debug, info, warn or error, info when empty, --loglevel wins
+	File       string // ./Logs/Log.log when empty
+	MaxSize    int    // megabytes before the file rotates
+	MaxBackups int    // rotated files to keep
+	MaxAge     int    // days to keep rotated files
+	Compress   bool   // gzip rotated files
+	StdoutOnly bool   // log to stdout and write no file
+	NoStdout   bool   // log to the file only
+	Json       bool   // one json object per line with chain, height, tx and phase fields
 }
 
 type PolyConfig struct {
-	RpcUrl                  string
+	RpcUrl                  string // kept for old configs, merged into RpcUrlList
+	RpcUrlList              []string
 	EntranceContractAddress string
 	WalletFile              string
+	WalletAccount           string // address or label of the wallet account, the default account when empty
+	RemoteSigner            string // unix socket of a signer daemon, the wallet is not opened when set
+	Pkcs11                  Pkcs11Config
+	FetchWorkers            uint64 // most poly blocks scanned at once while catching up
+	MaxInFlight             uint64 // most AddSignature txs waiting for confirmation at once
+	TxTimeout               uint64 // seconds a sent tx may stay unexecuted before it is sent again
+	MaxResubmits            uint64 // sends after the first before the event is marked failed
+
+	// poly keepers the header check starts from, later sets are only adopted
+	// once their config block verifies, like the peer ids of the genesis config
+	TrustedKeepers      []string
+	TrustedConfigHeight uint32 // config block of TrustedKeepers, 0 for genesis
+}
+
+// Pkcs11Config selects a secp256r1 key on a PKCS#11 token, used instead of the
+// wallet when Module is set
+type Pkcs11Config struct {
+	Module     string // path of the PKCS#11 library, like /usr/lib/softhsm/libsofthsm2.so
+	TokenLabel string
+	KeyLabel   string
+	Pin        string // falls back to the poly wallet password when empty
 }
 
 type NeoConfig struct {
-	SideChainId uint64
-	RpcUrlList  []string
-	CCMC        string // big endian string, like 0x1234567890abcdef123456781234567812345678
-	N2PContract string // neo to poly contract,  big endian string
+	SideChainId  uint64
+	RpcUrlList   []string
+	CCMC         string   // big endian string, like 0x1234567890abcdef123456781234567812345678
+	N2PContract  string   // neo to poly contract,  big endian string
+	FetchWorkers uint64   // most neo blocks fetched at once while catching up
+	WsUrlList    []string // neo-go websocket endpoints, like ws://127.0.0.1:20332/ws, monitorNeo polls RpcUrlList alone when empty
+
+	WalletFile       string   // NEP-6 wallet of a dedicated neo signing key, the poly key is reused when empty
+	SignerPublicKeys []string // compressed hex keys neo accepts signatures from, the poly keepers when empty
 }
 
 type ForceConfig struct {
@@ -38,31 +101,92 @@ type ForceConfig struct {
 	NeoStartHeight  uint32
 }
 
+// SignPolicy limits what poly to neo values get signed, empty allow lists allow everything
+type SignPolicy struct {
+	AllowFromChainIds  []uint64
+	DenyFromChainIds   []uint64
+	AllowFromContracts []string // hex of the source chain contract bytes
+	DenyFromContracts  []string
+	AllowToContracts   []string // big endian neo script hash, like CCMC
+	DenyToContracts    []string
+	AllowMethods       []string
+	DenyMethods        []string
+	AssetLimits        map[string]string // neo asset script hash => max amount per tx, decimal string
+	RateLimit          uint32            // signatures per RateWindow, 0 to disable
+	RateWindow         uint64            // seconds
+}
+
 // DefConfig Default config instance
 var DefConfig = NewConfig()
 
-//NewConfig retuen a TestConfig instance
+// NewConfig retuen a TestConfig instance
 func NewConfig() *Config {
 	return &Config{}
 }
 
-//Init TestConfig with a config file
-func (this *Config) Init(fileName string) error {
-	err := this.loadConfig(fileName)
+// Init loads the config in layers, each one overriding the one before:
+// the JSON, YAML or TOML file picked by extension, NEO3VOTER_* environment
+// variables, then Path=value sets from the command line
+func (this *Config) Init(fileName string, sets ...string) error {
+	err := this.loadConfig(fileName, sets)
 	if err != nil {
 		return fmt.Errorf("loadConfig error:%s", err)
 	}
 	return nil
 }
 
-func (this *Config) loadConfig(fileName string) error {
+func (this *Config) loadConfig(fileName string, sets []string) error {
 	data, err := this.readFile(fileName)
 	if err != nil {
 		return err
 	}
-	err = json.Unmarshal(data, this)
-	if err != nil {
-		return fmt.Errorf("json.Unmarshal TestConfig:%s error:%s", data, err)
+	if err = this.decode(fileName, data); err != nil {
+		return err
+	}
+	if err = this.applyEnv(); err != nil {
+		return err
+	}
+	if err = this.applySets(sets); err != nil {
+		return err
+	}
+	if this.PolyConfig.RpcUrl != "" {
+		this.PolyConfig.RpcUrlList = append([]string{this.PolyConfig.RpcUrl}, this.PolyConfig.RpcUrlList...)
+	}
+	// fetchNeoBlock compares script hashes in lower case
+	this.NeoConfig.CCMC = strings.ToLower(this.NeoConfig.CCMC)
+	this.NeoConfig.N2PContract = strings.ToLower(this.NeoConfig.N2PContract)
+	if this.ShutdownTimeout == 0 {
+		this.ShutdownTimeout = DEFAULT_SHUTDOWN_TIMEOUT
+	}
+	if this.HealthWindow == 0 {
+		this.HealthWindow = DEFAULT_HEALTH_WINDOW
+	}
+	if this.NeoConfig.FetchWorkers == 0 {
+		this.NeoConfig.FetchWorkers = DEFAULT_FETCH_WORKERS
+	}
+	if this.PolyConfig.FetchWorkers == 0 {
+		this.PolyConfig.FetchWorkers = DEFAULT_FETCH_WORKERS
+	}
+	if this.PolyConfig.MaxInFlight == 0 {
+		this.PolyConfig.MaxInFlight = DEFAULT_MAX_IN_FLIGHT
+	}
+	if this.PolyConfig.TxTimeout == 0 {
+		this.PolyConfig.TxTimeout = DEFAULT_TX_TIMEOUT
+	}
+	if this.PolyConfig.MaxResubmits == 0 {
+		this.PolyConfig.MaxResubmits = DEFAULT_MAX_RESUBMITS
+	}
+	if this.ReplayInterval == 0 {
+		this.ReplayInterval = DEFAULT_REPLAY_INTERVAL
+	}
+	if this.AuditLogFile == "" {
+		this.AuditLogFile = DEFAULT_AUDIT_LOG_FILE
+	}
+	if this.NeoPollInterval == 0 {
+		this.NeoPollInterval = DEFAULT_POLL_INTERVAL
+	}
+	if this.PolyPollInterval == 0 {
+		this.PolyPollInterval = DEFAULT_POLL_INTERVAL
 	}
 	return nil
 }
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ENV_PREFIX starts the environment variable of every config field, the rest
+// is the upper case field path joined by _, like NEO3VOTER_NEOCONFIG_RPCURLLIST
+const ENV_PREFIX = "NEO3VOTER_"
+
+// decode reads JSON, YAML or TOML by file extension. YAML and TOML go through
+// JSON so all three formats use the same field names.
+func (this *Config) decode(fileName string, data []byte) error {
+	var err error
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".yaml", ".yml":
+		var raw interface{}
+		if err = yaml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("yaml.Unmarshal %s error:%s", fileName, err)
+		}
+		data, err = json.Marshal(raw)
+	case ".toml":
+		var raw map[string]interface{}
+		if err = toml.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("toml.Unmarshal %s error:%s", fileName, err)
+		}
+		data, err = json.Marshal(raw)
+	}
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, this); err != nil {
+		return fmt.Errorf("json.Unmarshal %s error:%s", fileName, err)
+	}
+	return nil
+}
+
+// applyEnv overrides every field that has its environment variable set
+func (this *Config) applyEnv() error {
+	return walkFields(reflect.ValueOf(this).Elem(), nil, func(path []string, field reflect.Value) error {
+		name := ENV_PREFIX + strings.ToUpper(strings.Join(path, "_"))
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		if err := setField(field, raw); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		return nil
+	})
+}
+
+// applySets overrides fields from Path=value pairs, the path is the dotted
+// field path matched case insensitively, like NeoConfig.RpcUrlList=http://a,http://b
+func (this *Config) applySets(sets []string) error {
+	for _, set := range sets {
+		parts := strings.SplitN(set, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("override %q is not Path=value", set)
+		}
+		want := strings.ToLower(parts[0])
+		found := false
+		err := walkFields(reflect.ValueOf(this).Elem(), nil, func(path []string, field reflect.Value) error {
+			if strings.ToLower(strings.Join(path, ".")) != want {
+				return nil
+			}
+			found = true
+			return setField(field, parts[1])
+		})
+		if err != nil {
+			return fmt.Errorf("override %s: %v", parts[0], err)
+		}
+		if !found {
+			return fmt.Errorf("override %s: no such config field", parts[0])
+		}
+	}
+	return nil
+}
+
+// walkFields calls f on every non struct field below v with its field path
+func walkFields(v reflect.Value, path []string, f func(path []string, field reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		fieldPath := append(append([]string{}, path...), t.Field(i).Name)
+		var err error
+		if field.Kind() == reflect.Struct {
+			err = walkFields(field, fieldPath, f)
+		} else {
+			err = f(fieldPath, field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setField parses raw into field, lists are comma separated and maps are
+// comma separated key=value pairs
+func setField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Slice:
+		items := splitList(raw)
+		list := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setField(list.Index(i), item); err != nil {
+				return err
+			}
+		}
+		field.Set(list)
+	case reflect.Map:
+		m := reflect.MakeMap(field.Type())
+		for _, item := range splitList(raw) {
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("map entry %q is not key=value", item)
+			}
+			key := reflect.New(field.Type().Key()).Elem()
+			value := reflect.New(field.Type().Elem()).Elem()
+			if err := setField(key, kv[0]); err != nil {
+				return err
+			}
+			if err := setField(value, kv[1]); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		field.Set(m)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/polynetwork/neo3-voter/log"
+)
+
+var (
+	uint160Pattern     = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+	polyAddressPattern = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+)
+
+// Problems lists everything wrong with a config, one line each
+type Problems []string
+
+func (p Problems) Error() string {
+	return strings.Join(p, "\n")
+}
+
+func (p *Problems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// Validate checks the whole config and reports every problem at once
+func (this *Config) Validate() error {
+	var p Problems
+
+	checkUrls(&p, "PolyConfig.RpcUrlList", this.PolyConfig.RpcUrlList)
+	if !polyAddressPattern.MatchString(this.PolyConfig.EntranceContractAddress) {
+		p.add("PolyConfig.EntranceContractAddress %q is not a 40 hex digit poly contract address", this.PolyConfig.EntranceContractAddress)
+	}
+	switch {
+	case this.PolyConfig.RemoteSigner != "":
+	case this.PolyConfig.Pkcs11.Module != "":
+		checkFile(&p, "PolyConfig.Pkcs11.Module", this.PolyConfig.Pkcs11.Module)
+		if this.PolyConfig.Pkcs11.TokenLabel == "" || this.PolyConfig.Pkcs11.KeyLabel == "" {
+			p.add("PolyConfig.Pkcs11 needs TokenLabel and KeyLabel")
+		}
+	default:
+		checkFile(&p, "PolyConfig.WalletFile", this.PolyConfig.WalletFile)
+	}
+
+	if len(this.PolyConfig.TrustedKeepers) == 0 {
+		p.add("PolyConfig.TrustedKeepers is empty, poly headers can not be verified")
+	}
+	for _, key := range this.PolyConfig.TrustedKeepers {
+		if raw, err := hex.DecodeString(key); err != nil || len(raw) == 0 {
+			p.add("PolyConfig.TrustedKeepers %q is not a hex public key", key)
+		}
+	}
+
+	if this.NeoConfig.SideChainId == 0 {
+		p.add("NeoConfig.SideChainId is 0")
+	}
+	checkUrls(&p, "NeoConfig.RpcUrlList", this.NeoConfig.RpcUrlList)
+	checkUint160(&p, "NeoConfig.CCMC", this.NeoConfig.CCMC)
+	if this.NeoConfig.N2PContract != "" { // empty relays all contracts
+		checkUint160(&p, "NeoConfig.N2PContract", this.NeoConfig.N2PContract)
+	}
+	for _, raw := range this.NeoConfig.WsUrlList {
+		if u, err := url.Parse(raw); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			p.add("NeoConfig.WsUrlList %q is not a ws(s) url", raw)
+		}
+	}
+	if this.NeoConfig.WalletFile != "" {
+		checkFile(&p, "NeoConfig.WalletFile", this.NeoConfig.WalletFile)
+	}
+	for _, key := range this.NeoConfig.SignerPublicKeys {
+		if raw, err := hex.DecodeString(strings.TrimPrefix(key, "0x")); err != nil || len(raw) != 33 {
+			p.add("NeoConfig.SignerPublicKeys %q is not a compressed public key", key)
+		}
+	}
+
+	// the sign policy takes script hashes with or without 0x
+	for _, contract := range this.SignPolicy.AllowToContracts {
+		checkUint160(&p, "SignPolicy.AllowToContracts", "0x"+strings.TrimPrefix(contract, "0x"))
+	}
+	for _, contract := range this.SignPolicy.DenyToContracts {
+		checkUint160(&p, "SignPolicy.DenyToContracts", "0x"+strings.TrimPrefix(contract, "0x"))
+	}
+	for asset, limit := range this.SignPolicy.AssetLimits {
+		checkUint160(&p, "SignPolicy.AssetLimits asset", "0x"+strings.TrimPrefix(asset, "0x"))
+		if n, ok := new(big.Int).SetString(limit, 10); !ok || n.Sign() < 0 {
+			p.add("SignPolicy.AssetLimits %s limit %q is not a decimal amount", asset, limit)
+		}
+	}
+
+	if this.SignPolicy.RateLimit > 0 && this.SignPolicy.RateWindow == 0 {
+		p.add("SignPolicy.RateLimit needs a RateWindow")
+	}
+
+	checkDbPath(&p, this.BoltDbPath)
+	if this.Log.Level != "" {
+		if _, err := log.ParseLevel(this.Log.Level); err != nil {
+			p.add("Log.Level: %v", err)
+		}
+	}
+	if this.Log.MaxSize < 0 || this.Log.MaxBackups < 0 || this.Log.MaxAge < 0 {
+		p.add("Log.MaxSize, Log.MaxBackups and Log.MaxAge must not be negative")
+	}
+	if this.Log.StdoutOnly && this.Log.NoStdout {
+		p.add("Log.StdoutOnly and Log.NoStdout are both set")
+	}
+	if this.HttpAddr != "" {
+		if _, _, err := net.SplitHostPort(this.HttpAddr); err != nil {
+			p.add("HttpAddr %q: %v", this.HttpAddr, err)
+		}
+	}
+
+	if len(p) > 0 {
+		return p
+	}
+	return nil
+}
+
+func checkUrls(p *Problems, field string, urls []string) {
+	if len(urls) == 0 {
+		p.add("%s is empty", field)
+	}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			p.add("%s %q: %v", field, raw, err)
+			continue
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.add("%s %q is not an http(s) url", field, raw)
+		}
+	}
+}
+
+func checkUint160(p *Problems, field, value string) {
+	if !uint160Pattern.MatchString(value) {
+		p.add("%s %q is not a 0x prefixed big endian UInt160", field, value)
+	}
+}
+
+func checkFile(p *Problems, field, file string) {
+	if file == "" {
+		p.add("%s is empty", field)
+		return
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		p.add("%s: %v", field, err)
+	} else if info.IsDir() {
+		p.add("%s %s is a directory", field, file)
+	}
+}
+
+// checkDbPath resolves BoltDbPath like db.NewBoltDB and tries to create a file
+// next to the db
+func checkDbPath(p *Problems, dbPath string) {
+	if dbPath == "" {
+		p.add("BoltDbPath is empty")
+		return
+	}
+	dir := dbPath
+	if strings.Contains(dbPath, ".bin") {
+		dir = path.Dir(dbPath)
+	}
+	f, err := ioutil.TempFile(dir, ".voter-check")
+	if err != nil {
+		p.add("BoltDbPath %s is not writable: %v", dbPath, err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+}
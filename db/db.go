@@ -8,12 +8,14 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
 	BKTHeight     = []byte("Height")
 	PolyHeightKey = []byte("Poly")
 	NeoHeightKey  = []byte("Neo")
+	HealthKey     = []byte("Health")
 )
 
 type BoltDB struct {
@@ -30,20 +32,23 @@ func NewBoltDB(filePath string) (*BoltDB, error) {
 		filePath = path.Join(filePath, "bolt.bin")
 	}
 	w := new(BoltDB)
-	db, err := bolt.Open(filePath, 0644, &bolt.Options{InitialMmapSize: 500000})
+	// a running voter holds the file lock, commands give up instead of hanging
+	db, err := bolt.Open(filePath, 0644, &bolt.Options{InitialMmapSize: 500000, Timeout: 3 * time.Second})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("open %s: %v", filePath, err)
 	}
 	w.db = db
 	w.rwLock = new(sync.RWMutex)
 	w.filePath = filePath
-	// height
+	// buckets
 	if err = db.Update(func(btx *bolt.Tx) error {
-		_, err := btx.CreateBucketIfNotExists(BKTHeight)
-		if err != nil {
-			return err
+		for _, bkt := range [][]byte{BKTHeight, BKTNeoEvent, BKTPolyEvent, BKTQuarantine, BKTPolyTx, BKTPolyKeepers} {
+			_, err := btx.CreateBucketIfNotExists(bkt)
+			if err != nil {
+				return err
+			}
 		}
-		return nil
+		return migrateKeepers(btx)
 	}); err != nil {
 		return nil, err
 	}
@@ -123,6 +128,18 @@ func (w *BoltDB) GetNeoHeight() uint32 {
 	return height
 }
 
+// CheckWritable writes a probe key to make sure the db accepts updates
+func (w *BoltDB) CheckWritable() error {
+	w.rwLock.Lock()
+	defer w.rwLock.Unlock()
+
+	raw := make([]byte, 8)
+	binary.LittleEndian.PutUint64(raw, uint64(time.Now().Unix()))
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTHeight).Put(HealthKey, raw)
+	})
+}
+
 func (w *BoltDB) Close() {
 	w.rwLock.Lock()
 	w.db.Close()
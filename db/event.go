@@ -0,0 +1,156 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var (
+	BKTNeoEvent  = []byte("NeoEvent")
+	BKTPolyEvent = []byte("PolyEvent")
+)
+
+type EventStatus uint8
+
+const (
+	EventPending EventStatus = iota
+	EventSubmitted
+	EventConfirmed
+	EventFailed
+)
+
+func (s EventStatus) String() string {
+	switch s {
+	case EventPending:
+		return "pending"
+	case EventSubmitted:
+		return "submitted"
+	case EventConfirmed:
+		return "confirmed"
+	case EventFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// EventRecord tracks the handling of one neo lock event or one poly makeProof event
+type EventRecord struct {
+	Status     EventStatus
+	Height     uint32 // neo block of a lock event, poly block of a makeProof event
+	PolyTxHash string
+	CreatedAt  int64
+	UpdatedAt  int64
+}
+
+// NeoEventKey identifies a CrossChainLockEvent by neo tx hash and notification index
+func NeoEventKey(txHash string, index int) []byte {
+	return []byte(txHash + ":" + strconv.Itoa(index))
+}
+
+// PolyEventKey identifies a makeProof event by its cross states key
+func PolyEventKey(makeProofKey string) []byte {
+	return []byte(makeProofKey)
+}
+
+func (w *BoltDB) PutNeoEvent(key []byte, height uint32, status EventStatus, polyTxHash string) error {
+	return w.putEvent(BKTNeoEvent, key, height, status, polyTxHash)
+}
+
+func (w *BoltDB) GetNeoEvent(key []byte) *EventRecord {
+	return w.getEvent(BKTNeoEvent, key)
+}
+
+func (w *BoltDB) PutPolyEvent(key []byte, height uint32, status EventStatus, polyTxHash string) error {
+	return w.putEvent(BKTPolyEvent, key, height, status, polyTxHash)
+}
+
+func (w *BoltDB) GetPolyEvent(key []byte) *EventRecord {
+	return w.getEvent(BKTPolyEvent, key)
+}
+
+// FailedNeoEvents lists the lock events waiting to be voted again
+func (w *BoltDB) FailedNeoEvents() (map[string]*EventRecord, error) {
+	return w.failedEvents(BKTNeoEvent)
+}
+
+// FailedPolyEvents lists the makeProof events waiting to be signed again
+func (w *BoltDB) FailedPolyEvents() (map[string]*EventRecord, error) {
+	return w.failedEvents(BKTPolyEvent)
+}
+
+func (w *BoltDB) putEvent(bkt, key []byte, height uint32, status EventStatus, polyTxHash string) error {
+	w.rwLock.Lock()
+	defer w.rwLock.Unlock()
+
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return putEventTx(tx.Bucket(bkt), key, height, status, polyTxHash)
+	})
+}
+
+func putEventTx(bucket *bolt.Bucket, key []byte, height uint32, status EventStatus, polyTxHash string) error {
+	now := time.Now().Unix()
+	record := &EventRecord{CreatedAt: now}
+	if raw := bucket.Get(key); len(raw) > 0 {
+		if err := json.Unmarshal(raw, record); err != nil {
+			return err
+		}
+	}
+	record.Status = status
+	if height != 0 {
+		record.Height = height
+	}
+	if polyTxHash != "" {
+		record.PolyTxHash = polyTxHash
+	}
+	record.UpdatedAt = now
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
+}
+
+func (w *BoltDB) getEvent(bkt, key []byte) *EventRecord {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
+
+	var record *EventRecord
+	_ = w.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(bkt).Get(key)
+		if len(raw) == 0 {
+			return nil
+		}
+		r := new(EventRecord)
+		if err := json.Unmarshal(raw, r); err != nil {
+			return err
+		}
+		record = r
+		return nil
+	})
+
+	return record
+}
+
+func (w *BoltDB) failedEvents(bkt []byte) (map[string]*EventRecord, error) {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
+
+	records := make(map[string]*EventRecord)
+	err := w.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bkt).ForEach(func(k, v []byte) error {
+			r := new(EventRecord)
+			if err := json.Unmarshal(v, r); err != nil {
+				return err
+			}
+			if r.Status == EventFailed {
+				records[string(k)] = r
+			}
+			return nil
+		})
+	})
+	return records, err
+}
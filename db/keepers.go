@@ -0,0 +1,93 @@
+package db
+
+import (
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+)
+
+var BKTPolyKeepers = []byte("PolyKeepers")
+
+// KeepersRecord is a poly keeper set the voter verified, kept by config block
+// so events of older blocks can still be checked
+type KeepersRecord struct {
+	CfgHeight uint32
+	Peers     []string
+}
+
+func keepersKey(cfgHeight uint32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, cfgHeight)
+	return key
+}
+
+func (w *BoltDB) PutPolyKeepers(record *KeepersRecord) error {
+	w.rwLock.Lock()
+	defer w.rwLock.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTPolyKeepers).Put(keepersKey(record.CfgHeight), data)
+	})
+}
+
+// GetPolyKeepers returns the set of a config block
+func (w *BoltDB) GetPolyKeepers(cfgHeight uint32) *KeepersRecord {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
+
+	var record *KeepersRecord
+	_ = w.db.View(func(tx *bolt.Tx) error {
+		record = decodeKeepers(tx.Bucket(BKTPolyKeepers).Get(keepersKey(cfgHeight)))
+		return nil
+	})
+	return record
+}
+
+// LatestPolyKeepers returns the set of the newest verified config block
+func (w *BoltDB) LatestPolyKeepers() *KeepersRecord {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
+
+	var record *KeepersRecord
+	_ = w.db.View(func(tx *bolt.Tx) error {
+		_, raw := tx.Bucket(BKTPolyKeepers).Cursor().Last()
+		record = decodeKeepers(raw)
+		return nil
+	})
+	return record
+}
+
+func decodeKeepers(raw []byte) *KeepersRecord {
+	if len(raw) == 0 {
+		return nil
+	}
+	r := new(KeepersRecord)
+	if err := json.Unmarshal(raw, r); err != nil {
+		return nil
+	}
+	return r
+}
+
+// legacyKeepersKey held the one latest set before sets were kept by config block
+var legacyKeepersKey = []byte("Latest")
+
+// migrateKeepers moves a set saved under the legacy key to its config block
+func migrateKeepers(btx *bolt.Tx) error {
+	bucket := btx.Bucket(BKTPolyKeepers)
+	raw := bucket.Get(legacyKeepersKey)
+	if len(raw) == 0 {
+		return nil
+	}
+	raw = append([]byte(nil), raw...)
+	if record := decodeKeepers(raw); record != nil {
+		if err := bucket.Put(keepersKey(record.CfgHeight), raw); err != nil {
+			return err
+		}
+	}
+	return bucket.Delete(legacyKeepersKey)
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var BKTQuarantine = []byte("Quarantine")
+
+// QuarantineRecord is a poly to neo value the sign policy refused, kept for manual review
+type QuarantineRecord struct {
+	PolyHeight uint32
+	Value      string // hex of the ToMerkleValue
+	Reason     string
+	CreatedAt  int64
+	Approved   bool // released by an operator, signed without the sign policy
+}
+
+func (w *BoltDB) PutQuarantine(makeProofKey string, record *QuarantineRecord) error {
+	w.rwLock.Lock()
+	defer w.rwLock.Unlock()
+
+	if record.CreatedAt == 0 {
+		record.CreatedAt = time.Now().Unix()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTQuarantine).Put([]byte(makeProofKey), data)
+	})
+}
+
+func (w *BoltDB) GetQuarantine(makeProofKey string) *QuarantineRecord {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
+
+	var record *QuarantineRecord
+	_ = w.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(BKTQuarantine).Get([]byte(makeProofKey))
+		if len(raw) == 0 {
+			return nil
+		}
+		r := new(QuarantineRecord)
+		if err := json.Unmarshal(raw, r); err != nil {
+			return err
+		}
+		record = r
+		return nil
+	})
+
+	return record
+}
+
+func (w *BoltDB) ListQuarantine() (map[string]*QuarantineRecord, error) {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
+
+	records := make(map[string]*QuarantineRecord)
+	err := w.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTQuarantine).ForEach(func(k, v []byte) error {
+			r := new(QuarantineRecord)
+			if err := json.Unmarshal(v, r); err != nil {
+				return err
+			}
+			records[string(k)] = r
+			return nil
+		})
+	})
+	return records, err
+}
+
+// ReleaseQuarantine approves the quarantined value of the key and marks its
+// makeProof event failed, so the voter signs it on the next replay without
+// checking the sign policy, a different value under the key is checked again
+func (w *BoltDB) ReleaseQuarantine(makeProofKey string) error {
+	w.rwLock.Lock()
+	defer w.rwLock.Unlock()
+
+	return w.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(BKTQuarantine)
+		raw := bucket.Get([]byte(makeProofKey))
+		if len(raw) == 0 {
+			return fmt.Errorf("makeProof key %s is not quarantined", makeProofKey)
+		}
+		record := new(QuarantineRecord)
+		if err := json.Unmarshal(raw, record); err != nil {
+			return err
+		}
+		if record.Approved {
+			return fmt.Errorf("makeProof key %s is released already", makeProofKey)
+		}
+		record.Approved = true
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if err = bucket.Put([]byte(makeProofKey), data); err != nil {
+			return err
+		}
+		return putEventTx(tx.Bucket(BKTPolyEvent), PolyEventKey(makeProofKey), record.PolyHeight, EventFailed, "")
+	})
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+)
+
+var BKTPolyTx = []byte("PolyTx")
+
+const (
+	PolyTxVote      = "vote"
+	PolyTxSignature = "signature"
+)
+
+// PolyTxRecord is a poly tx waiting for confirmation, with what it takes to
+// send it again
+type PolyTxRecord struct {
+	Kind     string // PolyTxVote or PolyTxSignature
+	EventKey string // NeoEventKey of a vote, PolyEventKey of a signature
+	Height   uint32 // neo height of a vote, poly height of a signature
+
+	NeoTxHash    string // vote
+	StorageKey   string // vote, hex
+	CrossChainId string // vote, hex of the MakeTxParam CrossChainID poly marks done
+	Subject      string // signature, hex of the ToMerkleValue
+	Signature    string // signature, hex
+	AuditSeq     uint64 // signature, the audit record of the signature
+	AuditHash    string // signature
+
+	Attempts    int
+	FirstSentAt int64
+	SentAt      int64
+}
+
+func (w *BoltDB) PutPolyTx(txHash string, record *PolyTxRecord) error {
+	return w.ReplacePolyTx("", txHash, record)
+}
+
+// ReplacePolyTx swaps the tx of a resubmitted record at once, old may be empty
+func (w *BoltDB) ReplacePolyTx(old, txHash string, record *PolyTxRecord) error {
+	w.rwLock.Lock()
+	defer w.rwLock.Unlock()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return w.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(BKTPolyTx)
+		if old != "" && old != txHash {
+			if err := bucket.Delete([]byte(old)); err != nil {
+				return err
+			}
+		}
+		return bucket.Put([]byte(txHash), data)
+	})
+}
+
+// FinishPolyTx settles the event of a tracked tx and stops tracking the tx in
+// one transaction, so an event is never left submitted without its tx
+func (w *BoltDB) FinishPolyTx(txHash string, record *PolyTxRecord, status EventStatus) error {
+	w.rwLock.Lock()
+	defer w.rwLock.Unlock()
+
+	bkt := BKTPolyEvent
+	if record.Kind == PolyTxVote {
+		bkt = BKTNeoEvent
+	}
+	return w.db.Update(func(tx *bolt.Tx) error {
+		if err := putEventTx(tx.Bucket(bkt), []byte(record.EventKey), record.Height, status, ""); err != nil {
+			return err
+		}
+		return tx.Bucket(BKTPolyTx).Delete([]byte(txHash))
+	})
+}
+
+func (w *BoltDB) GetPolyTx(txHash string) *PolyTxRecord {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
+
+	var record *PolyTxRecord
+	_ = w.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(BKTPolyTx).Get([]byte(txHash))
+		if len(raw) == 0 {
+			return nil
+		}
+		r := new(PolyTxRecord)
+		if err := json.Unmarshal(raw, r); err != nil {
+			return err
+		}
+		record = r
+		return nil
+	})
+
+	return record
+}
+
+// ListPolyTx returns every tracked tx by hash
+func (w *BoltDB) ListPolyTx() (map[string]*PolyTxRecord, error) {
+	w.rwLock.RLock()
+	defer w.rwLock.RUnlock()
+
+	records := make(map[string]*PolyTxRecord)
+	err := w.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(BKTPolyTx).ForEach(func(k, v []byte) error {
+			r := new(PolyTxRecord)
+			if err := json.Unmarshal(v, r); err != nil {
+				return err
+			}
+			records[string(k)] = r
+			return nil
+		})
+	})
+	return records, err
+}
@@ -3,13 +3,19 @@ module github.com/polynetwork/neo3-voter
 go 1.17
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/boltdb/bolt v1.3.1
-	github.com/joeqian10/EasyLogger v1.0.0
+	github.com/gookit/color v1.4.2
+	github.com/gorilla/websocket v1.4.2
 	github.com/joeqian10/neo3-gogogo v1.1.2
+	github.com/miekg/pkcs11 v1.1.1
+	github.com/natefinch/lumberjack v2.0.0+incompatible
 	github.com/ontio/ontology-crypto v1.2.1
 	github.com/polynetwork/poly v0.0.0-20210112063446-24e3d053e9d6
 	github.com/polynetwork/poly-go-sdk v0.0.0-20210114120411-3dcba035134f
+	github.com/prometheus/client_golang v1.8.0
 	github.com/urfave/cli v1.22.4
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -21,6 +27,7 @@ require (
 	github.com/Workiva/go-datastructures v1.0.52 // indirect
 	github.com/Zilliqa/gozilliqa-sdk v1.2.1-0.20210927032600-4c733f2cb879 // indirect
 	github.com/aristanetworks/goarista v0.0.0-20170210015632-ea17b1a17847 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/blocktree/go-owcrypt v1.1.10 // indirect
 	github.com/btcsuite/btcd v0.22.0-beta // indirect
 	github.com/btcsuite/btclog v0.0.0-20170628155309-84c8d2346e9f // indirect
@@ -49,8 +56,6 @@ require (
 	github.com/golang/protobuf v1.5.0 // indirect
 	github.com/golang/snappy v0.0.3-0.20201103224600-674baa8c7fc3 // indirect
 	github.com/google/btree v1.0.0 // indirect
-	github.com/gookit/color v1.4.2 // indirect
-	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/gtank/merlin v0.1.1 // indirect
 	github.com/gtank/ristretto255 v0.1.2 // indirect
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
@@ -61,8 +66,8 @@ require (
 	github.com/joeqian10/neo-gogogo v1.1.0 // indirect
 	github.com/joeqian10/neo3-gogogo-legacy v1.0.0 // indirect
 	github.com/mattn/go-runewidth v0.0.4 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
 	github.com/mimoo/StrobeGo v0.0.0-20181016162300-f8f6d4d2b643 // indirect
-	github.com/natefinch/lumberjack v2.0.0+incompatible // indirect
 	github.com/novifinancial/serde-reflection/serde-generate/runtime/golang v0.0.0-20210526181959-1694c58d103e // indirect
 	github.com/olekukonko/tablewriter v0.0.2-0.20190409134802-7e037d187b0c // indirect
 	github.com/ontio/go-bip32 v0.0.0-20190520025953-d3cea6894a2b // indirect
@@ -73,6 +78,9 @@ require (
 	github.com/phoreproject/bls v0.0.0-20200525203911-a88a5ae26844 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.2.0 // indirect
+	github.com/prometheus/common v0.14.0 // indirect
+	github.com/prometheus/procfs v0.2.0 // indirect
 	github.com/prometheus/tsdb v0.7.1 // indirect
 	github.com/renlulu/gozilliqa-sdklegacy v0.0.0-20210926114807-88a08c5ab803 // indirect
 	github.com/russross/blackfriday/v2 v2.0.1 // indirect
@@ -105,7 +113,6 @@ require (
 	google.golang.org/protobuf v1.26.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0 // indirect
 	gopkg.in/natefinch/npipe.v2 v2.0.0-20160621034901-c1b8fa8bdcce // indirect
-	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
 )
 
 replace github.com/polynetwork/poly-go-sdk => github.com/zhiqiangxu/poly-go-sdk v0.0.0-20220118102343-71f305556b24
@@ -1,18 +1,334 @@
 package log
 
 import (
-	"github.com/joeqian10/EasyLogger"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/natefinch/lumberjack"
+)
+
+// levels as taken by --loglevel
+const (
+	TraceLevel = iota
+	DebugLevel
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+	MaxLevel
 )
 
-var Log = EasyLogger.NewRotatingEasyLogger(
-	"./Logs/Log.log",
-	10,
-	30,
-	30,
-	true,
-	false,
-	log.Ldate|log.Lmicroseconds,
-	"",
-	true,
+var (
+	levelNames  = []string{"trace", "debug", "info", "warn", "error", "fatal", "max"}
+	levelLabels = []string{
+		color.Cyan.Sprint("[TRACE]"),
+		color.Blue.Sprint("[DEBUG]"),
+		color.Green.Sprint("[INFO ]"),
+		color.Yellow.Sprint("[WARN ]"),
+		color.Red.Sprint("[ERROR]"),
+		color.Magenta.Sprint("[FATAL]"),
+	}
 )
+
+// Options says where and how to log, zero sizes keep the defaults
+type Options struct {
+	File       string // ./Logs/Log.log when empty
+	MaxSize    int    // megabytes before the file rotates
+	MaxBackups int    // rotated files to keep
+	MaxAge     int    // days to keep rotated files
+	Compress   bool
+	StdoutOnly bool // no file at all
+	NoStdout   bool // file only
+	Json       bool // one json object per line instead of text
+}
+
+// Fields are structured values attached to a message, like chain, height,
+// tx hash and phase
+type Fields map[string]interface{}
+
+// Logger drops messages below its level, which can change while running
+type Logger struct {
+	level int32
+
+	mu     sync.RWMutex
+	out    *log.Logger
+	closer io.Closer
+	json   bool
+}
+
+var Log = New(Options{})
+
+func New(opts Options) *Logger {
+	l := &Logger{level: InfoLevel}
+	l.Setup(opts)
+	return l
+}
+
+// Setup swaps the output, messages already written stay where they went
+func (l *Logger) Setup(opts Options) {
+	if opts.File == "" {
+		opts.File = "./Logs/Log.log"
+	}
+	if opts.MaxSize == 0 {
+		opts.MaxSize = 10
+	}
+	if opts.MaxBackups == 0 {
+		opts.MaxBackups = 30
+	}
+	if opts.MaxAge == 0 {
+		opts.MaxAge = 30
+	}
+
+	var ws []io.Writer
+	var closer io.Closer
+	if !opts.StdoutOnly {
+		lum := &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSize,
+			MaxAge:     opts.MaxAge,
+			MaxBackups: opts.MaxBackups,
+			LocalTime:  true,
+			Compress:   opts.Compress,
+		}
+		ws = append(ws, lum)
+		closer = lum
+	}
+	if opts.StdoutOnly || !opts.NoStdout {
+		ws = append(ws, os.Stdout)
+	}
+	flags := log.Ldate | log.Lmicroseconds
+	if opts.Json {
+		flags = 0
+	}
+
+	l.mu.Lock()
+	old := l.closer
+	l.out = log.New(io.MultiWriter(ws...), "", flags)
+	l.closer = closer
+	l.json = opts.Json
+	l.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+}
+
+// ParseLevel takes a level name or number, like info or 2
+func ParseLevel(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for level, name := range levelNames {
+		if s == name {
+			return level, nil
+		}
+	}
+	if level, err := strconv.Atoi(s); err == nil && level >= TraceLevel && level <= MaxLevel {
+		return level, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+func LevelName(level int) string {
+	if level < TraceLevel || level > MaxLevel {
+		return strconv.Itoa(level)
+	}
+	return levelNames[level]
+}
+
+func (l *Logger) SetLevel(level int) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+func (l *Logger) Level() int {
+	return int(atomic.LoadInt32(&l.level))
+}
+
+// WithFields returns an entry that adds fields to every message it logs
+func (l *Logger) WithFields(fields Fields) *Entry {
+	return &Entry{logger: l, fields: fields}
+}
+
+func (l *Logger) write(level int, fields Fields, msg string) {
+	if level < l.Level() {
+		return
+	}
+	caller := ""
+	if level <= DebugLevel {
+		if _, file, line, ok := runtime.Caller(2); ok {
+			caller = filepath.Base(file) + ":" + strconv.Itoa(line)
+		}
+	}
+
+	l.mu.RLock()
+	out, asJson := l.out, l.json
+	l.mu.RUnlock()
+
+	if asJson {
+		record := make(map[string]interface{}, len(fields)+4)
+		for k, v := range fields {
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			record[k] = v
+		}
+		record["time"] = time.Now().Format(time.RFC3339Nano)
+		record["level"] = levelNames[level]
+		record["msg"] = msg
+		if caller != "" {
+			record["caller"] = caller
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			data = []byte(fmt.Sprintf(`{"level":"error","msg":"marshal log record: %v"}`, err))
+		}
+		out.Output(0, string(data))
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s GID %d, ", levelLabels[level], gid())
+	if caller != "" {
+		buf.WriteString(caller + " ")
+	}
+	buf.WriteString(msg)
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&buf, " %s=%v", k, fields[k])
+	}
+	out.Output(0, buf.String())
+}
+
+func gid() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	b = b[:bytes.IndexByte(b, ' ')]
+	n, _ := strconv.ParseUint(string(b), 10, 64)
+	return n
+}
+
+func (l *Logger) Trace(a ...interface{}) {
+	l.write(TraceLevel, nil, sprint(a))
+}
+
+func (l *Logger) Tracef(format string, a ...interface{}) {
+	l.write(TraceLevel, nil, fmt.Sprintf(format, a...))
+}
+
+func (l *Logger) Debug(a ...interface{}) {
+	l.write(DebugLevel, nil, sprint(a))
+}
+
+func (l *Logger) Debugf(format string, a ...interface{}) {
+	l.write(DebugLevel, nil, fmt.Sprintf(format, a...))
+}
+
+func (l *Logger) Info(a ...interface{}) {
+	l.write(InfoLevel, nil, sprint(a))
+}
+
+func (l *Logger) Infof(format string, a ...interface{}) {
+	l.write(InfoLevel, nil, fmt.Sprintf(format, a...))
+}
+
+func (l *Logger) Warn(a ...interface{}) {
+	l.write(WarnLevel, nil, sprint(a))
+}
+
+func (l *Logger) Warnf(format string, a ...interface{}) {
+	l.write(WarnLevel, nil, fmt.Sprintf(format, a...))
+}
+
+func (l *Logger) Error(a ...interface{}) {
+	l.write(ErrorLevel, nil, sprint(a))
+}
+
+func (l *Logger) Errorf(format string, a ...interface{}) {
+	l.write(ErrorLevel, nil, fmt.Sprintf(format, a...))
+}
+
+func (l *Logger) Fatal(a ...interface{}) {
+	l.write(FatalLevel, nil, sprint(a))
+}
+
+func (l *Logger) Fatalf(format string, a ...interface{}) {
+	l.write(FatalLevel, nil, fmt.Sprintf(format, a...))
+}
+
+// Entry is a logger bound to fields
+type Entry struct {
+	logger *Logger
+	fields Fields
+}
+
+// WithFields returns an entry with both sets of fields, the new ones win
+func (e *Entry) WithFields(fields Fields) *Entry {
+	merged := make(Fields, len(e.fields)+len(fields))
+	for k, v := range e.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Entry{logger: e.logger, fields: merged}
+}
+
+func (e *Entry) Trace(a ...interface{}) {
+	e.logger.write(TraceLevel, e.fields, sprint(a))
+}
+
+func (e *Entry) Tracef(format string, a ...interface{}) {
+	e.logger.write(TraceLevel, e.fields, fmt.Sprintf(format, a...))
+}
+
+func (e *Entry) Debug(a ...interface{}) {
+	e.logger.write(DebugLevel, e.fields, sprint(a))
+}
+
+func (e *Entry) Debugf(format string, a ...interface{}) {
+	e.logger.write(DebugLevel, e.fields, fmt.Sprintf(format, a...))
+}
+
+func (e *Entry) Info(a ...interface{}) {
+	e.logger.write(InfoLevel, e.fields, sprint(a))
+}
+
+func (e *Entry) Infof(format string, a ...interface{}) {
+	e.logger.write(InfoLevel, e.fields, fmt.Sprintf(format, a...))
+}
+
+func (e *Entry) Warn(a ...interface{}) {
+	e.logger.write(WarnLevel, e.fields, sprint(a))
+}
+
+func (e *Entry) Warnf(format string, a ...interface{}) {
+	e.logger.write(WarnLevel, e.fields, fmt.Sprintf(format, a...))
+}
+
+func (e *Entry) Error(a ...interface{}) {
+	e.logger.write(ErrorLevel, e.fields, sprint(a))
+}
+
+func (e *Entry) Errorf(format string, a ...interface{}) {
+	e.logger.write(ErrorLevel, e.fields, fmt.Sprintf(format, a...))
+}
+
+// sprint joins like fmt.Sprintln without the line break
+func sprint(a []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(a...), "\n")
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/polynetwork/neo3-voter/voter"
 	"github.com/polynetwork/poly/core/types"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
@@ -14,8 +19,10 @@ import (
 	"github.com/polynetwork/neo3-voter/common"
 	"github.com/polynetwork/neo3-voter/config"
 	"github.com/polynetwork/neo3-voter/log"
+	"github.com/polynetwork/neo3-voter/signer"
 
 	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/urfave/cli"
 )
 
@@ -28,9 +35,19 @@ func setupApp() *cli.App {
 	app.Copyright = "Copyright in 2022 The NEO Project"
 	app.Flags = []cli.Flag{
 		cmd.ConfigPathFlag,
-		cmd.PolyPwd,
+		cmd.LogLevelFlag,
+		cmd.SetFlag,
+		cmd.AccountFlag,
+	}
+	app.Flags = append(app.Flags, cmd.PolyPwdFlags...)
+	app.Flags = append(app.Flags, cmd.NeoPwdFlags...)
+	app.Commands = []cli.Command{
+		signerCommand,
+		accountsCommand,
+		configCommand,
+		auditCommand,
+		quarantineCommand,
 	}
-	app.Commands = []cli.Command{}
 	app.Before = func(context *cli.Context) error {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 		return nil
@@ -45,43 +62,141 @@ func main() {
 	}
 }
 
-func start(ctx *cli.Context) {
+func start(ctx *cli.Context) error {
 	configPath := ctx.String(cmd.GetFlagName(cmd.ConfigPathFlag))
-	err := config.DefConfig.Init(configPath)
+	err := config.DefConfig.Init(configPath, ctx.StringSlice(cmd.GetFlagName(cmd.SetFlag))...)
 	if err != nil {
-		fmt.Println("DefConfig.Init error: ", err)
-		return
+		return fmt.Errorf("DefConfig.Init error: %v", err)
 	}
+	if err = config.DefConfig.Validate(); err != nil {
+		return fmt.Errorf("config %s has problems:\n%v", configPath, err)
+	}
+	logConf := config.DefConfig.Log
+	Log.Setup(log.Options{
+		File:       logConf.File,
+		MaxSize:    logConf.MaxSize,
+		MaxBackups: logConf.MaxBackups,
+		MaxAge:     logConf.MaxAge,
+		Compress:   logConf.Compress,
+		StdoutOnly: logConf.StdoutOnly,
+		NoStdout:   logConf.NoStdout,
+		Json:       logConf.Json,
+	})
+	setLogLevel(ctx, config.DefConfig)
 
-	polyPwd := ctx.GlobalString(cmd.GetFlagName(cmd.PolyPwd))
-
-	//create poly RPC Client
-	polySdk := sdk.NewPolySdk()
-	err = SetUpPoly(polySdk, config.DefConfig.PolyConfig.RpcUrl)
+	//create poly RPC Clients
+	polyPool, err := voter.NewPolyPool(config.DefConfig.PolyConfig.RpcUrlList, SetUpPoly)
 	if err != nil {
-		panic(fmt.Errorf("failed to set up poly: %v", err))
+		return fmt.Errorf("failed to set up poly: %v", err)
 	}
 
-	// Get wallet account for poly
-	signer, ok := common.GetAccountByPassword(polySdk, config.DefConfig.PolyConfig.WalletFile, polyPwd)
-	if !ok {
-		Log.Errorf("[NEO Relayer] common.GetAccountByPassword error")
-		return
+	// Get signer for poly and neo
+	var s signer.Signer
+	if config.DefConfig.PolyConfig.RemoteSigner != "" {
+		s, err = signer.NewRemoteSigner(config.DefConfig.PolyConfig.RemoteSigner)
+		if err != nil {
+			return fmt.Errorf("[NEO Relayer] signer.NewRemoteSigner error: %v", err)
+		}
+	} else if config.DefConfig.PolyConfig.Pkcs11.Module != "" {
+		conf := config.DefConfig.PolyConfig.Pkcs11
+		if conf.Pin == "" {
+			pin, err := cmd.PolyPasswordSource(ctx).Read()
+			if err != nil {
+				return fmt.Errorf("[NEO Relayer] read pkcs11 pin error: %v", err)
+			}
+			conf.Pin = string(pin)
+		}
+		s, err = signer.NewPkcs11Signer(&conf)
+		if err != nil {
+			return fmt.Errorf("[NEO Relayer] signer.NewPkcs11Signer error: %v", err)
+		}
+	} else {
+		account, ok := common.GetAccountByPassword(polyPool.Sdk(), config.DefConfig.PolyConfig.WalletFile, walletAccount(ctx), cmd.PolyPasswordSource(ctx))
+		if !ok {
+			return fmt.Errorf("[NEO Relayer] common.GetAccountByPassword error")
+		}
+		s, err = signer.NewWalletSigner(account)
+		if err != nil {
+			return fmt.Errorf("[NEO Relayer] signer.NewWalletSigner error: %v", err)
+		}
+	}
+
+	if neoWallet := config.DefConfig.NeoConfig.WalletFile; neoWallet != "" {
+		pwd, err := cmd.NeoPasswordSource(ctx).Read()
+		if err != nil {
+			return fmt.Errorf("[NEO Relayer] read neo wallet password error: %v", err)
+		}
+		pair, err := signer.LoadNeoKey(neoWallet, string(pwd))
+		if err != nil {
+			return fmt.Errorf("[NEO Relayer] signer.LoadNeoKey error: %v", err)
+		}
+		s = signer.WithNeoKey(s, pair)
+	}
+
+	address := s.Address()
+	Log.Infof("voter %s, neo key %s", address.ToBase58(), hex.EncodeToString(s.NeoPublicKey().EncodePoint(true)))
+	v := voter.New(polyPool, s, config.DefConfig)
+	if err = v.Start(context.Background()); err != nil {
+		if c, ok := s.(io.Closer); ok {
+			c.Close()
+		}
+		return err
+	}
+
+	var srv *http.Server
+	if config.DefConfig.HttpAddr != "" {
+		srv = serveHttp(config.DefConfig.HttpAddr, v)
+	}
+
+	waitToExit(func() {
+		reloadConfig(ctx, v)
+	})
+	if srv != nil {
+		srv.Close()
+	}
+	v.Stop()
+	if c, ok := s.(io.Closer); ok {
+		c.Close()
 	}
+	return nil
+}
 
-	Log.Infof("voter %s", signer.Address.ToBase58())
-	v := voter.New(polySdk, signer, config.DefConfig)
-	v.Start()
+// walletAccount prefers the account given on the command line over the config
+func walletAccount(ctx *cli.Context) string {
+	if account := ctx.String(cmd.GetFlagName(cmd.AccountFlag)); account != "" {
+		return account
+	}
+	return config.DefConfig.PolyConfig.WalletAccount
+}
 
-	waitToExit()
+func serveHttp(addr string, v *voter.Voter) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthHandler(v.Liveness))
+	mux.HandleFunc("/readyz", healthHandler(v.Readiness))
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		Log.Infof("http server listening on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			Log.Errorf("http server on %s failed: %v", addr, err)
+		}
+	}()
+	return srv
 }
 
-func waitToExit() {
+// waitToExit returns on SIGINT or SIGTERM, SIGHUP calls reload instead when
+// given and exits as well otherwise
+func waitToExit(reload func()) {
 	exit := make(chan bool, 0)
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	go func() {
 		for sig := range sc {
+			if sig == syscall.SIGHUP && reload != nil {
+				Log.Infof("Neo Relayer received %v, reloading config.", sig.String())
+				reload()
+				continue
+			}
 			Log.Infof("Neo Relayer received exit signal: %v.", sig.String())
 			close(exit)
 			break
@@ -90,6 +205,55 @@ func waitToExit() {
 	<-exit
 }
 
+// reloadConfig reads the config file again and hands it to the voter, a config
+// with problems or identity changes is dropped as a whole
+func reloadConfig(ctx *cli.Context, v *voter.Voter) {
+	configPath := ctx.String(cmd.GetFlagName(cmd.ConfigPathFlag))
+	conf := config.NewConfig()
+	if err := conf.Init(configPath, ctx.StringSlice(cmd.GetFlagName(cmd.SetFlag))...); err != nil {
+		Log.Errorf("reload config %s failed: %v", configPath, err)
+		return
+	}
+	if err := conf.Validate(); err != nil {
+		Log.Errorf("reload config %s has problems:\n%v", configPath, err)
+		return
+	}
+	if err := v.Reload(conf); err != nil {
+		Log.Errorf("reload config %s rejected: %v", configPath, err)
+		return
+	}
+	setLogLevel(ctx, conf)
+}
+
+// setLogLevel applies --loglevel if given, else Log.Level of the config
+func setLogLevel(ctx *cli.Context, conf *config.Config) {
+	level := int(ctx.GlobalUint(cmd.GetFlagName(cmd.LogLevelFlag)))
+	if !ctx.GlobalIsSet(cmd.GetFlagName(cmd.LogLevelFlag)) && conf.Log.Level != "" {
+		level, _ = log.ParseLevel(conf.Log.Level) // checked by Validate
+	}
+	Log.SetLevel(level)
+	Log.Infof("log level %s", log.LevelName(level))
+}
+
+// healthHandler answers 503 with the failed checks if any check reports an error
+func healthHandler(checks func() map[string]error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		result := make(map[string]string)
+		for name, err := range checks() {
+			if err != nil {
+				status = http.StatusServiceUnavailable
+				result[name] = err.Error()
+			} else {
+				result[name] = "ok"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
+
 func SetUpPoly(poly *sdk.PolySdk, rpcAddr string) error {
 	poly.NewRpcClient().SetAddress(rpcAddr)
 	c1 := make(chan *types.Header, 1)
@@ -100,16 +264,17 @@ func SetUpPoly(poly *sdk.PolySdk, rpcAddr string) error {
 		hdr, err := poly.GetHeaderByHeight(0)
 		if err != nil {
 			c2 <- err
+			return
 		}
 		c1 <- hdr
 	}()
 
 	select {
-	case hdr := <- c1:
+	case hdr := <-c1:
 		poly.SetChainId(hdr.ChainID)
-	case err := <- c2:
-		return  err
-	case <- time.After(time.Second * 5):
+	case err := <-c2:
+		return err
+	case <-time.After(time.Second * 5):
 		return fmt.Errorf("poly rpc port timeout")
 	}
 
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ChainNeo  = "neo"
+	ChainPoly = "poly"
+
+	KindVote      = "vote"
+	KindSignature = "signature"
+
+	ResultSubmitted = "submitted"
+	ResultSucceeded = "succeeded"
+	ResultFailed    = "failed"
+	ResultRejected  = "rejected"
+)
+
+var (
+	ChainHeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "voter",
+		Name:      "chain_height",
+		Help:      "Latest block height reported by the chain.",
+	}, []string{"chain"})
+
+	ProcessedHeight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "voter",
+		Name:      "processed_height",
+		Help:      "Next block height the voter is going to process.",
+	}, []string{"chain"})
+
+	HeightLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "voter",
+		Name:      "height_lag",
+		Help:      "Blocks between the chain tip and the processed height.",
+	}, []string{"chain"})
+
+	Submissions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "voter",
+		Name:      "submissions_total",
+		Help:      "Votes and signatures sent to poly by result.",
+	}, []string{"kind", "result"})
+
+	NeoRpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "voter",
+		Name:      "neo_rpc_duration_seconds",
+		Help:      "Latency of neo rpc calls per endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"url", "method"})
+
+	NeoRpcErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "voter",
+		Name:      "neo_rpc_errors_total",
+		Help:      "Failed neo rpc calls per endpoint.",
+	}, []string{"url", "method"})
+
+	NeoRpcHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "voter",
+		Name:      "neo_rpc_healthy",
+		Help:      "Whether the neo rpc endpoint is used by the client pool.",
+	}, []string{"url"})
+
+	NeoProofIncidents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "voter",
+		Name:      "neo_proof_incidents_total",
+		Help:      "Neo state proofs that failed local verification per endpoint.",
+	}, []string{"url"})
+
+	PolyRpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "voter",
+		Name:      "poly_rpc_duration_seconds",
+		Help:      "Latency of poly rpc calls per endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"url", "method"})
+
+	PolyRpcErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "voter",
+		Name:      "poly_rpc_errors_total",
+		Help:      "Failed poly rpc calls per endpoint.",
+	}, []string{"url", "method"})
+
+	PolyRpcHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "voter",
+		Name:      "poly_rpc_healthy",
+		Help:      "Whether the poly rpc endpoint is preferred by the client pool.",
+	}, []string{"url"})
+
+	WaitTxDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "voter",
+		Name:      "wait_tx_duration_seconds",
+		Help:      "Time from the first send of a poly transaction until the tracker settles it.",
+		Buckets:   []float64{1, 2, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"result"})
+
+	NeoStateRootHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "voter",
+		Name:      "neo_state_root_height",
+		Help:      "Height of the latest witnessed neo state root used for votes.",
+	})
+
+	FetchWorkers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "voter",
+		Name:      "fetch_workers",
+		Help:      "Blocks fetched concurrently ahead of the processed height.",
+	}, []string{"chain"})
+
+	PolyErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "voter",
+		Name:      "poly_errors_total",
+		Help:      "Errors of poly votes and signatures by class.",
+	}, []string{"kind", "class"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		ChainHeight,
+		ProcessedHeight,
+		HeightLag,
+		Submissions,
+		NeoRpcDuration,
+		NeoRpcErrors,
+		NeoRpcHealthy,
+		NeoProofIncidents,
+		PolyRpcDuration,
+		PolyRpcErrors,
+		PolyRpcHealthy,
+		WaitTxDuration,
+		NeoStateRootHeight,
+		FetchWorkers,
+		PolyErrors,
+	)
+}
+
+// SetHeights updates tip, cursor and lag of a chain at once
+func SetHeights(chain string, height, processed uint32) {
+	ChainHeight.WithLabelValues(chain).Set(float64(height))
+	ProcessedHeight.WithLabelValues(chain).Set(float64(processed))
+	HeightLag.WithLabelValues(chain).Set(float64(height) - float64(processed))
+}
+
+func ObserveNeoRpc(url, method string, start time.Time, failed bool) {
+	NeoRpcDuration.WithLabelValues(url, method).Observe(time.Since(start).Seconds())
+	if failed {
+		NeoRpcErrors.WithLabelValues(url, method).Inc()
+	}
+}
+
+func ObservePolyRpc(url, method string, start time.Time, failed bool) {
+	PolyRpcDuration.WithLabelValues(url, method).Observe(time.Since(start).Seconds())
+	if failed {
+		PolyRpcErrors.WithLabelValues(url, method).Inc()
+	}
+}
+
+func ObserveWaitTx(start time.Time, err error) {
+	result := ResultSucceeded
+	if err != nil {
+		result = ResultFailed
+	}
+	WaitTxDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+}
+
+func IncSubmission(kind, result string) {
+	Submissions.WithLabelValues(kind, result).Inc()
+}
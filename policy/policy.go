@@ -0,0 +1,181 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joeqian10/neo3-gogogo/helper"
+	"github.com/polynetwork/neo3-voter/config"
+	pCommon "github.com/polynetwork/poly/common"
+	ccmCommon "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+)
+
+// ErrRateLimited means the value is fine but has to wait for the next window
+var ErrRateLimited = errors.New("signing rate limit reached")
+
+// Violation is a rule the value breaks, such values go to quarantine
+type Violation struct {
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return "policy violation: " + v.Reason
+}
+
+type list struct {
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+func newList(allow, deny []string) *list {
+	l := &list{allow: make(map[string]bool), deny: make(map[string]bool)}
+	for _, s := range allow {
+		l.allow[normalize(s)] = true
+	}
+	for _, s := range deny {
+		l.deny[normalize(s)] = true
+	}
+	return l
+}
+
+// permits applies deny first, an empty allow list allows everything else
+func (l *list) permits(s string) bool {
+	s = normalize(s)
+	if l.deny[s] {
+		return false
+	}
+	return len(l.allow) == 0 || l.allow[s]
+}
+
+func normalize(s string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x")
+}
+
+// Policy decides whether a poly to neo ToMerkleValue may be signed
+type Policy struct {
+	fromChains    *list
+	fromContracts *list
+	toContracts   *list
+	methods       *list
+	assetLimits   map[string]*big.Int
+	rateLimit     int
+	rateWindow    time.Duration
+
+	mu     sync.Mutex
+	signed []time.Time
+}
+
+func New(conf *config.SignPolicy) (*Policy, error) {
+	var allowChains, denyChains []string
+	for _, id := range conf.AllowFromChainIds {
+		allowChains = append(allowChains, fmt.Sprint(id))
+	}
+	for _, id := range conf.DenyFromChainIds {
+		denyChains = append(denyChains, fmt.Sprint(id))
+	}
+	p := &Policy{
+		fromChains:    newList(allowChains, denyChains),
+		fromContracts: newList(conf.AllowFromContracts, conf.DenyFromContracts),
+		toContracts:   newList(conf.AllowToContracts, conf.DenyToContracts),
+		methods:       newList(conf.AllowMethods, conf.DenyMethods),
+		assetLimits:   make(map[string]*big.Int),
+		rateLimit:     int(conf.RateLimit),
+		rateWindow:    time.Duration(conf.RateWindow) * time.Second,
+	}
+	for asset, limit := range conf.AssetLimits {
+		n, ok := new(big.Int).SetString(limit, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid amount limit %s of asset %s", limit, asset)
+		}
+		p.assetLimits[normalize(asset)] = n
+	}
+	return p, nil
+}
+
+// Check returns a *Violation, ErrRateLimited when the rate limit has no slot
+// left, or nil. The slot is only taken by Signed once the signature is out.
+func (p *Policy) Check(value *ccmCommon.ToMerkleValue) error {
+	param := value.MakeTxParam
+	if !p.fromChains.permits(fmt.Sprint(value.FromChainID)) {
+		return &Violation{fmt.Sprintf("source chain %d not allowed", value.FromChainID)}
+	}
+	if !p.fromContracts.permits(helper.BytesToHex(param.FromContractAddress)) {
+		return &Violation{fmt.Sprintf("source contract %x not allowed", param.FromContractAddress)}
+	}
+	toContract := scriptHash(param.ToContractAddress)
+	if !p.toContracts.permits(toContract) {
+		return &Violation{fmt.Sprintf("target contract %s not allowed", toContract)}
+	}
+	if !p.methods.permits(param.Method) {
+		return &Violation{fmt.Sprintf("method %s not allowed", param.Method)}
+	}
+	if len(p.assetLimits) > 0 {
+		asset, amount, err := decodeTxArgs(param.Args)
+		if err != nil {
+			return &Violation{fmt.Sprintf("undecodable args %x: %v", param.Args, err)}
+		}
+		if limit, ok := p.assetLimits[normalize(asset)]; ok && amount.Cmp(limit) > 0 {
+			return &Violation{fmt.Sprintf("amount %s of asset %s exceeds %s", amount, asset, limit)}
+		}
+	}
+	if p.rateLimit > 0 && p.recent() >= p.rateLimit {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Signed takes a slot of the rate limit for a submitted signature
+func (p *Policy) Signed() {
+	if p.rateLimit == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.signed = append(p.signed, time.Now())
+}
+
+// recent drops the slots that left the window and counts the rest
+func (p *Policy) recent() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	kept := p.signed[:0]
+	for _, t := range p.signed {
+		if now.Sub(t) < p.rateWindow {
+			kept = append(kept, t)
+		}
+	}
+	p.signed = kept
+	return len(p.signed)
+}
+
+// decodeTxArgs reads the lock proxy unlock args: to asset hash, to address and
+// a 32 bytes little endian amount
+func decodeTxArgs(args []byte) (asset string, amount *big.Int, err error) {
+	source := pCommon.NewZeroCopySource(args)
+	assetHash, eof := source.NextVarBytes()
+	if eof {
+		return "", nil, fmt.Errorf("read to asset hash")
+	}
+	if _, eof = source.NextVarBytes(); eof {
+		return "", nil, fmt.Errorf("read to address")
+	}
+	raw, eof := source.NextBytes(32)
+	if eof {
+		return "", nil, fmt.Errorf("read amount")
+	}
+	return scriptHash(assetHash), new(big.Int).SetBytes(helper.ReverseBytes(raw)), nil
+}
+
+// scriptHash formats little endian neo script hash bytes like the config does
+func scriptHash(b []byte) string {
+	if len(b) != 20 {
+		return helper.BytesToHex(b)
+	}
+	return "0x" + helper.UInt160FromBytes(b).String()
+}
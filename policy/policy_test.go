@@ -0,0 +1,133 @@
+package policy
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/joeqian10/neo3-gogogo/helper"
+	"github.com/polynetwork/neo3-voter/config"
+	pCommon "github.com/polynetwork/poly/common"
+	ccmCommon "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+)
+
+var (
+	testAsset    = helper.HexToBytes("cf76e28bd0062c4a478ee35561011319f3cfa4d2") // little endian
+	testProxy    = helper.HexToBytes("25820465d41a57dca24529e88387ac2d78722778")
+	testFrom     = "e14fdd69cf7bf6afb9265ac806e09fea438df7b8"
+	testAssetKey = "0x" + helper.UInt160FromBytes(testAsset).String()
+	testProxyKey = "0x" + helper.UInt160FromBytes(testProxy).String()
+)
+
+// testArgs encodes unlock args the way the lock proxy does
+func testArgs(asset []byte, amount int64) []byte {
+	sink := pCommon.NewZeroCopySink(nil)
+	sink.WriteVarBytes(asset)
+	sink.WriteVarBytes([]byte{1, 2, 3})
+	raw := make([]byte, 32)
+	copy(raw, helper.ReverseBytes(big.NewInt(amount).Bytes()))
+	sink.WriteBytes(raw)
+	return sink.Bytes()
+}
+
+func testValue(chainId uint64, method string, args []byte) *ccmCommon.ToMerkleValue {
+	return &ccmCommon.ToMerkleValue{
+		FromChainID: chainId,
+		MakeTxParam: &ccmCommon.MakeTxParam{
+			FromContractAddress: helper.HexToBytes(testFrom),
+			ToChainID:           14,
+			ToContractAddress:   testProxy,
+			Method:              method,
+			Args:                args,
+		},
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	args := testArgs(testAsset, 100)
+	for _, tc := range []struct {
+		name      string
+		conf      config.SignPolicy
+		value     *ccmCommon.ToMerkleValue
+		violation bool
+	}{
+		{"empty policy", config.SignPolicy{}, testValue(2, "unlock", args), false},
+		{"allowed chain", config.SignPolicy{AllowFromChainIds: []uint64{2}}, testValue(2, "unlock", args), false},
+		{"chain not allowed", config.SignPolicy{AllowFromChainIds: []uint64{6}}, testValue(2, "unlock", args), true},
+		{"deny wins over allow", config.SignPolicy{AllowFromChainIds: []uint64{2}, DenyFromChainIds: []uint64{2}}, testValue(2, "unlock", args), true},
+		{"denied source contract", config.SignPolicy{DenyFromContracts: []string{"0x" + testFrom}}, testValue(2, "unlock", args), true},
+		{"allowed target padded", config.SignPolicy{AllowToContracts: []string{" " + testProxyKey + " "}}, testValue(2, "unlock", args), false},
+		{"target not allowed", config.SignPolicy{AllowToContracts: []string{testAssetKey}}, testValue(2, "unlock", args), true},
+		{"method not allowed", config.SignPolicy{AllowMethods: []string{"unlock"}}, testValue(2, "mint", args), true},
+		{"under limit", config.SignPolicy{AssetLimits: map[string]string{testAssetKey: "100"}}, testValue(2, "unlock", args), false},
+		{"limit exceeded", config.SignPolicy{AssetLimits: map[string]string{testAssetKey: "99"}}, testValue(2, "unlock", args), true},
+		{"limit of another asset", config.SignPolicy{AssetLimits: map[string]string{testProxyKey: "1"}}, testValue(2, "unlock", args), false},
+		{"truncated args", config.SignPolicy{AssetLimits: map[string]string{testAssetKey: "100"}}, testValue(2, "unlock", args[:len(args)-1]), true},
+		{"truncated args without limits", config.SignPolicy{}, testValue(2, "unlock", args[:len(args)-1]), false},
+	} {
+		p, err := New(&tc.conf)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		err = p.Check(tc.value)
+		var violation *Violation
+		if errors.As(err, &violation) != tc.violation {
+			t.Fatalf("%s: got %v, want violation %v", tc.name, err, tc.violation)
+		}
+		if !tc.violation && err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+	}
+}
+
+func TestPolicyRateLimit(t *testing.T) {
+	p, err := New(&config.SignPolicy{RateLimit: 2, RateWindow: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := testValue(2, "unlock", nil)
+	for i := 0; i < 2; i++ {
+		if err = p.Check(value); err != nil {
+			t.Fatalf("signature %d: %v", i, err)
+		}
+		p.Signed()
+	}
+	if err = p.Check(value); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want %v", err, ErrRateLimited)
+	}
+}
+
+func TestDecodeTxArgs(t *testing.T) {
+	args := testArgs(testAsset, 1e18)
+	for _, tc := range []struct {
+		name   string
+		args   []byte
+		asset  string
+		amount string
+	}{
+		{"full", args, testAssetKey, "1000000000000000000"},
+		{"empty", nil, "", ""},
+		{"no address", args[:21], "", ""},
+		{"short amount", args[:len(args)-1], "", ""},
+	} {
+		asset, amount, err := decodeTxArgs(tc.args)
+		if tc.asset == "" {
+			if err == nil {
+				t.Fatalf("%s: decoded %s %s", tc.name, asset, amount)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if asset != tc.asset || amount.String() != tc.amount {
+			t.Fatalf("%s: got %s %s, want %s %s", tc.name, asset, amount, tc.asset, tc.amount)
+		}
+	}
+}
+
+func TestNewRejectsBadLimit(t *testing.T) {
+	if _, err := New(&config.SignPolicy{AssetLimits: map[string]string{testAssetKey: "1e6"}}); err == nil {
+		t.Fatal("limit 1e6 accepted")
+	}
+}
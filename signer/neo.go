@@ -0,0 +1,68 @@
+package signer
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/joeqian10/neo3-gogogo/crypto"
+	"github.com/joeqian10/neo3-gogogo/helper"
+	"github.com/joeqian10/neo3-gogogo/keys"
+	"github.com/joeqian10/neo3-gogogo/wallet"
+)
+
+// LoadNeoKey decrypts the default account of a NEP-6 wallet, or its only
+// account holding a key when none is marked default
+func LoadNeoKey(path, password string) (*keys.KeyPair, error) {
+	w, err := wallet.NewNEP6Wallet(path, &helper.DefaultProtocolSettings, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open neo wallet %s: %v", path, err)
+	}
+	if err = w.Unlock(password); err != nil {
+		return nil, fmt.Errorf("unlock neo wallet %s: %v", path, err)
+	}
+	var account *wallet.NEP6Account
+	var withKey int
+	for i := range w.Accounts {
+		acc := &w.Accounts[i]
+		if !acc.HasKey() {
+			continue
+		}
+		withKey++
+		if acc.GetIsDefault() || account == nil {
+			account = acc
+		}
+	}
+	if account == nil {
+		return nil, fmt.Errorf("neo wallet %s has no account with a key", path)
+	}
+	if withKey > 1 && !account.GetIsDefault() {
+		return nil, fmt.Errorf("neo wallet %s has %d accounts and no default one", path, withKey)
+	}
+	return account.GetKey()
+}
+
+// neoKeySigner signs poly transactions with the wrapped signer and neo values
+// with its own key, so either key can be rotated alone
+type neoKeySigner struct {
+	Signer
+	pair *keys.KeyPair
+}
+
+func WithNeoKey(s Signer, pair *keys.KeyPair) Signer {
+	return &neoKeySigner{Signer: s, pair: pair}
+}
+
+func (n *neoKeySigner) Close() error {
+	if c, ok := n.Signer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (n *neoKeySigner) NeoPublicKey() *crypto.ECPoint {
+	return n.pair.PublicKey
+}
+
+func (n *neoKeySigner) SignForNeo(data []byte) ([]byte, error) {
+	return n.pair.Sign(data)
+}
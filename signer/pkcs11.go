@@ -0,0 +1,241 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/joeqian10/neo3-gogogo/crypto"
+	"github.com/miekg/pkcs11"
+	"github.com/ontio/ontology-crypto/ec"
+	"github.com/ontio/ontology-crypto/keypair"
+	s "github.com/ontio/ontology-crypto/signature"
+	"github.com/polynetwork/neo3-voter/config"
+	"github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+)
+
+var oidP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+
+// pkcs11Signer signs inside the token, the private key never leaves it. Poly
+// and neo both use ecdsa over sha256 on P-256 with a 64 byte r||s signature,
+// so one key serves both chains like the wallet signer does.
+type pkcs11Signer struct {
+	conf *config.Pkcs11Config
+	ctx  *pkcs11.Ctx
+
+	mu      sync.Mutex
+	session pkcs11.SessionHandle
+	open    bool
+	key     pkcs11.ObjectHandle
+
+	polyPub keypair.PublicKey
+	address common.Address
+	neoPub  *crypto.ECPoint
+}
+
+// NewPkcs11Signer logs into the token, both halves of the key pair carry
+// conf.KeyLabel as CKA_LABEL
+func NewPkcs11Signer(conf *config.Pkcs11Config) (Signer, error) {
+	ctx := pkcs11.New(conf.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("load pkcs11 module %s failed", conf.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("initialize pkcs11 module: %v", err)
+	}
+	p := &pkcs11Signer{conf: conf, ctx: ctx}
+	if err := p.login(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	pub, err := p.publicKey()
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	curve := elliptic.P256()
+	p.neoPub, err = crypto.CreateECPoint(pub.X, pub.Y, &curve)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	p.polyPub = &ec.PublicKey{Algorithm: ec.ECDSA, PublicKey: pub}
+	p.address = types.AddressFromPubKey(p.polyPub)
+	return p, nil
+}
+
+// login opens a session on the configured token and finds the private key
+func (p *pkcs11Signer) login() error {
+	slots, err := p.ctx.GetSlotList(true)
+	if err != nil {
+		return fmt.Errorf("pkcs11 slot list: %v", err)
+	}
+	slot, found := uint(0), false
+	for _, id := range slots {
+		info, err := p.ctx.GetTokenInfo(id)
+		if err == nil && info.Label == p.conf.TokenLabel {
+			slot, found = id, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("pkcs11 token %q not found", p.conf.TokenLabel)
+	}
+	session, err := p.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("pkcs11 open session: %v", err)
+	}
+	p.session, p.open = session, true
+	if err = p.ctx.Login(session, pkcs11.CKU_USER, p.conf.Pin); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return fmt.Errorf("pkcs11 login: %v", err)
+	}
+	p.key, err = p.findObject(pkcs11.CKO_PRIVATE_KEY)
+	return err
+}
+
+func (p *pkcs11Signer) findObject(class uint) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.conf.KeyLabel),
+	}
+	if err := p.ctx.FindObjectsInit(p.session, template); err != nil {
+		return 0, fmt.Errorf("pkcs11 find key: %v", err)
+	}
+	objs, _, err := p.ctx.FindObjects(p.session, 2)
+	_ = p.ctx.FindObjectsFinal(p.session)
+	if err != nil {
+		return 0, fmt.Errorf("pkcs11 find key: %v", err)
+	}
+	if len(objs) != 1 {
+		return 0, fmt.Errorf("pkcs11 found %d ec keys of class %d labeled %q", len(objs), class, p.conf.KeyLabel)
+	}
+	return objs[0], nil
+}
+
+func (p *pkcs11Signer) publicKey() (*ecdsa.PublicKey, error) {
+	obj, err := p.findObject(pkcs11.CKO_PUBLIC_KEY)
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := p.ctx.GetAttributeValue(p.session, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11 read public key: %v", err)
+	}
+	var oid asn1.ObjectIdentifier
+	if _, err = asn1.Unmarshal(attrs[0].Value, &oid); err != nil || !oid.Equal(oidP256) {
+		return nil, fmt.Errorf("pkcs11 key %q is not on secp256r1", p.conf.KeyLabel)
+	}
+	// the point should be a DER octet string, some tokens return it bare
+	point := attrs[1].Value
+	var inner []byte
+	if rest, err := asn1.Unmarshal(point, &inner); err == nil && len(rest) == 0 {
+		point = inner
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), point)
+	if x == nil {
+		return nil, fmt.Errorf("pkcs11 key %q has an invalid ec point", p.conf.KeyLabel)
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+// sign hashes data with sha256 and lets the token sign the digest, the session
+// is reopened once if the token dropped it
+func (p *pkcs11Signer) sign(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for retry := 0; ; retry++ {
+		if !p.open {
+			if err := p.login(); err != nil {
+				return nil, err
+			}
+		}
+		mech := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}
+		err := p.ctx.SignInit(p.session, mech, p.key)
+		var sig []byte
+		if err == nil {
+			sig, err = p.ctx.Sign(p.session, digest[:])
+		}
+		if err != nil && retry == 0 && sessionLost(err) {
+			_ = p.ctx.CloseSession(p.session)
+			p.open = false
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("pkcs11 sign: %v", err)
+		}
+		if len(sig) != 64 {
+			return nil, fmt.Errorf("pkcs11 sign: unexpected signature length %d", len(sig))
+		}
+		return sig, nil
+	}
+}
+
+func sessionLost(err error) bool {
+	var e pkcs11.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e {
+	case pkcs11.CKR_SESSION_HANDLE_INVALID, pkcs11.CKR_SESSION_CLOSED, pkcs11.CKR_USER_NOT_LOGGED_IN,
+		pkcs11.CKR_DEVICE_REMOVED, pkcs11.CKR_TOKEN_NOT_PRESENT:
+		return true
+	}
+	return false
+}
+
+// Close logs out and unloads the module
+func (p *pkcs11Signer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.open {
+		_ = p.ctx.Logout(p.session)
+		_ = p.ctx.CloseSession(p.session)
+		p.open = false
+	}
+	err := p.ctx.Finalize()
+	p.ctx.Destroy()
+	return err
+}
+
+// Sign returns r||s, which is how poly serializes SHA256withECDSA signatures
+func (p *pkcs11Signer) Sign(data []byte) ([]byte, error) {
+	return p.sign(data)
+}
+
+func (p *pkcs11Signer) GetPublicKey() keypair.PublicKey {
+	return p.polyPub
+}
+
+func (p *pkcs11Signer) GetPrivateKey() keypair.PrivateKey {
+	return nil
+}
+
+func (p *pkcs11Signer) GetSigScheme() s.SignatureScheme {
+	return s.SHA256withECDSA
+}
+
+func (p *pkcs11Signer) Address() common.Address {
+	return p.address
+}
+
+func (p *pkcs11Signer) NeoPublicKey() *crypto.ECPoint {
+	return p.neoPub
+}
+
+func (p *pkcs11Signer) SignForNeo(data []byte) ([]byte, error) {
+	return p.sign(data)
+}
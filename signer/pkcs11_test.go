@@ -0,0 +1,159 @@
+//go:build softhsm
+// +build softhsm
+
+package signer
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"github.com/ontio/ontology-crypto/keypair"
+	s "github.com/ontio/ontology-crypto/signature"
+	"github.com/polynetwork/neo3-voter/config"
+)
+
+// run with go test -tags softhsm ./signer, SOFTHSM2_MODULE overrides the
+// library path
+const defaultSoftHSMModule = "/usr/lib/softhsm/libsofthsm2.so"
+
+// newSoftHSMToken initializes a token in a temporary SoftHSM store and creates
+// a secp256r1 key pair on it
+func newSoftHSMToken(t *testing.T) *config.Pkcs11Config {
+	module := os.Getenv("SOFTHSM2_MODULE")
+	if module == "" {
+		module = defaultSoftHSMModule
+	}
+	if _, err := os.Stat(module); err != nil {
+		t.Skipf("softhsm module: %v", err)
+	}
+	dir := t.TempDir()
+	tokens := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokens, 0700); err != nil {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(dir, "softhsm2.conf")
+	if err := ioutil.WriteFile(conf, []byte(fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\n", tokens)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	c := &config.Pkcs11Config{Module: module, TokenLabel: "voter", KeyLabel: "voter-key", Pin: "1234"}
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Fatalf("load %s failed", module)
+	}
+	// the signer initializes the module itself, so let it go before returning
+	defer ctx.Destroy()
+	defer ctx.Finalize()
+	if err := ctx.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	slots, err := ctx.GetSlotList(false)
+	if err != nil || len(slots) == 0 {
+		t.Fatalf("slot list: %v %v", slots, err)
+	}
+	if err = ctx.InitToken(slots[0], "5678", c.TokenLabel); err != nil {
+		t.Fatal(err)
+	}
+	// softhsm moves an initialized token to a new slot
+	slots, err = ctx.GetSlotList(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var slot uint
+	for _, id := range slots {
+		if info, err := ctx.GetTokenInfo(id); err == nil && info.Label == c.TokenLabel {
+			slot = id
+		}
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ctx.Login(session, pkcs11.CKU_SO, "5678"); err != nil {
+		t.Fatal(err)
+	}
+	if err = ctx.InitPIN(session, c.Pin); err != nil {
+		t.Fatal(err)
+	}
+	if err = ctx.Logout(session); err != nil {
+		t.Fatal(err)
+	}
+	if err = ctx.Login(session, pkcs11.CKU_USER, c.Pin); err != nil {
+		t.Fatal(err)
+	}
+	params, err := asn1.Marshal(oidP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, c.KeyLabel),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, c.KeyLabel),
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ctx.Logout(session)
+	_ = ctx.CloseSession(session)
+	return c
+}
+
+func TestPkcs11SignerSoftHSM(t *testing.T) {
+	sgn, err := NewPkcs11Signer(newSoftHSMToken(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sgn.(io.Closer).Close()
+
+	pub := keypair.SerializePublicKey(sgn.GetPublicKey())
+	if len(pub) != 33 {
+		t.Fatalf("public key %x is not compressed secp256r1", pub)
+	}
+	if neo := sgn.NeoPublicKey().EncodePoint(true); string(neo) != string(pub) {
+		t.Fatalf("neo key %x, poly key %x", neo, pub)
+	}
+
+	for _, tc := range []struct {
+		name string
+		sign func([]byte) ([]byte, error)
+	}{
+		{"poly", sgn.Sign},
+		{"neo", sgn.SignForNeo},
+	} {
+		data := []byte("to merkle value of " + tc.name)
+		raw, err := tc.sign(data)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(raw) != 64 {
+			t.Fatalf("%s: signature of %d bytes, want r||s", tc.name, len(raw))
+		}
+		sig, err := s.Deserialize(raw)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !s.Verify(sgn.GetPublicKey(), data, sig) {
+			t.Fatalf("%s: signature does not verify", tc.name)
+		}
+		if s.Verify(sgn.GetPublicKey(), append(data, 0), sig) {
+			t.Fatalf("%s: signature verifies other data", tc.name)
+		}
+	}
+}
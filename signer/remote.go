@@ -0,0 +1,174 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"sync"
+
+	"github.com/joeqian10/neo3-gogogo/crypto"
+	"github.com/ontio/ontology-crypto/keypair"
+	s "github.com/ontio/ontology-crypto/signature"
+	"github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+)
+
+const serviceName = "Signer"
+
+type KeysArgs struct{}
+
+type KeysReply struct {
+	PolyPublicKey []byte // keypair.SerializePublicKey
+	SigScheme     uint8
+	NeoPublicKey  []byte // compressed ec point
+}
+
+type SignArgs struct {
+	Data []byte
+}
+
+type SignReply struct {
+	Signature []byte
+}
+
+// Service exposes a Signer over json rpc, it is what the signer daemon serves
+type Service struct {
+	signer Signer
+}
+
+func (svc *Service) Keys(_ KeysArgs, reply *KeysReply) error {
+	reply.PolyPublicKey = keypair.SerializePublicKey(svc.signer.GetPublicKey())
+	reply.SigScheme = uint8(svc.signer.GetSigScheme())
+	reply.NeoPublicKey = svc.signer.NeoPublicKey().EncodePoint(true)
+	return nil
+}
+
+func (svc *Service) SignPoly(args SignArgs, reply *SignReply) (err error) {
+	reply.Signature, err = svc.signer.Sign(args.Data)
+	return
+}
+
+func (svc *Service) SignNeo(args SignArgs, reply *SignReply) (err error) {
+	reply.Signature, err = svc.signer.SignForNeo(args.Data)
+	return
+}
+
+// Serve answers signing requests on a unix socket only the owner can use
+// until the returned listener is closed
+func Serve(socket string, signer Signer) (net.Listener, error) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(serviceName, &Service{signer: signer}); err != nil {
+		return nil, err
+	}
+	_ = os.Remove(socket)
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+	return l, nil
+}
+
+// remoteSigner forwards every signature to a signer daemon
+type remoteSigner struct {
+	socket string
+
+	mu     sync.Mutex
+	client *rpc.Client
+
+	polyPub keypair.PublicKey
+	scheme  s.SignatureScheme
+	address common.Address
+	neoPub  *crypto.ECPoint
+}
+
+func NewRemoteSigner(socket string) (Signer, error) {
+	r := &remoteSigner{socket: socket}
+	reply := new(KeysReply)
+	if err := r.call("Keys", KeysArgs{}, reply); err != nil {
+		return nil, fmt.Errorf("remote signer keys: %v", err)
+	}
+	pub, err := keypair.DeserializePublicKey(reply.PolyPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer poly public key: %v", err)
+	}
+	r.neoPub, err = crypto.NewECPointFromBytes(reply.NeoPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer neo public key: %v", err)
+	}
+	r.polyPub = pub
+	r.scheme = s.SignatureScheme(reply.SigScheme)
+	r.address = types.AddressFromPubKey(pub)
+	return r, nil
+}
+
+// call redials once when the daemon went away since the last call
+func (r *remoteSigner) call(method string, args, reply interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for retry := 0; ; retry++ {
+		if r.client == nil {
+			conn, err := net.Dial("unix", r.socket)
+			if err != nil {
+				return err
+			}
+			r.client = jsonrpc.NewClient(conn)
+		}
+		err := r.client.Call(serviceName+"."+method, args, reply)
+		if retry == 0 && (errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			r.client.Close()
+			r.client = nil
+			continue
+		}
+		return err
+	}
+}
+
+func (r *remoteSigner) Sign(data []byte) ([]byte, error) {
+	reply := new(SignReply)
+	err := r.call("SignPoly", SignArgs{Data: data}, reply)
+	return reply.Signature, err
+}
+
+func (r *remoteSigner) GetPublicKey() keypair.PublicKey {
+	return r.polyPub
+}
+
+func (r *remoteSigner) GetPrivateKey() keypair.PrivateKey {
+	return nil
+}
+
+func (r *remoteSigner) GetSigScheme() s.SignatureScheme {
+	return r.scheme
+}
+
+func (r *remoteSigner) Address() common.Address {
+	return r.address
+}
+
+func (r *remoteSigner) NeoPublicKey() *crypto.ECPoint {
+	return r.neoPub
+}
+
+func (r *remoteSigner) SignForNeo(data []byte) ([]byte, error) {
+	reply := new(SignReply)
+	err := r.call("SignNeo", SignArgs{Data: data}, reply)
+	return reply.Signature, err
+}
@@ -0,0 +1,102 @@
+package signer
+
+import (
+	"crypto/elliptic"
+	"fmt"
+
+	"github.com/joeqian10/neo3-gogogo/crypto"
+	"github.com/joeqian10/neo3-gogogo/keys"
+	"github.com/ontio/ontology-crypto/ec"
+	"github.com/ontio/ontology-crypto/keypair"
+	s "github.com/ontio/ontology-crypto/signature"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly/common"
+)
+
+// Signer produces every signature of the voter: poly transactions through the
+// embedded sdk.Signer and the neo curve signatures over poly to neo values.
+// GetPrivateKey returns nil when the key is held elsewhere.
+type Signer interface {
+	sdk.Signer
+	Address() common.Address
+	NeoPublicKey() *crypto.ECPoint
+	SignForNeo(data []byte) ([]byte, error)
+}
+
+// walletSigner keeps the poly wallet account in process and signs for neo with
+// the same private key on neo's curve and hash
+type walletSigner struct {
+	account *sdk.Account
+	pair    *keys.KeyPair
+}
+
+func NewWalletSigner(account *sdk.Account) (Signer, error) {
+	pkBytes, err := polyPrivateKey2Hex(account.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	pair, err := keys.NewKeyPair(pkBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &walletSigner{account: account, pair: pair}, nil
+}
+
+func (w *walletSigner) Sign(data []byte) ([]byte, error) {
+	return w.account.Sign(data)
+}
+
+func (w *walletSigner) GetPublicKey() keypair.PublicKey {
+	return w.account.GetPublicKey()
+}
+
+func (w *walletSigner) GetPrivateKey() keypair.PrivateKey {
+	return w.account.GetPrivateKey()
+}
+
+func (w *walletSigner) GetSigScheme() s.SignatureScheme {
+	return w.account.GetSigScheme()
+}
+
+func (w *walletSigner) Address() common.Address {
+	return w.account.Address
+}
+
+func (w *walletSigner) NeoPublicKey() *crypto.ECPoint {
+	return w.pair.PublicKey
+}
+
+func (w *walletSigner) SignForNeo(data []byte) ([]byte, error) {
+	return w.pair.Sign(data)
+}
+
+// NeoPublicKeyOf returns the neo key of a poly P-256 key, which is what the
+// wallet signer signs for neo with
+func NeoPublicKeyOf(pub keypair.PublicKey) (*crypto.ECPoint, error) {
+	key, ok := pub.(*ec.PublicKey)
+	if !ok || key.Algorithm != ec.ECDSA || key.Params().Name != elliptic.P256().Params().Name {
+		return nil, fmt.Errorf("not a P-256 ecdsa key")
+	}
+	curve := elliptic.P256()
+	return crypto.CreateECPoint(key.X, key.Y, &curve)
+}
+
+func polyPrivateKey2Hex(pri keypair.PrivateKey) ([]byte, error) {
+	switch t := pri.(type) {
+	case *ec.PrivateKey:
+		switch t.Algorithm {
+		case ec.ECDSA:
+
+		default:
+			return nil, fmt.Errorf("unsupported pk")
+		}
+		Nlen := (t.Params().BitSize + 7) >> 3
+		skBytes := t.D.Bytes()
+		skEncoded := make([]byte, Nlen)
+		// pad sk with zeroes
+		copy(skEncoded[Nlen-len(skBytes):], skBytes)
+		return skEncoded, nil
+	default:
+		return nil, fmt.Errorf("unkown private key type")
+	}
+}
@@ -0,0 +1,84 @@
+package voter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const probeTimeout = time.Second * 3
+
+func (v *Voter) beatNeo() {
+	atomic.StoreInt64(&v.neoBeat, time.Now().Unix())
+}
+
+func (v *Voter) beatPoly() {
+	atomic.StoreInt64(&v.polyBeat, time.Now().Unix())
+}
+
+// Liveness checks that both monitor loops advanced within HealthWindow
+func (v *Voter) Liveness() map[string]error {
+	return map[string]error{
+		"monitorNeo":  v.checkBeat(&v.neoBeat),
+		"monitorPoly": v.checkBeat(&v.polyBeat),
+	}
+}
+
+// Readiness adds rpc reachability and db writability to Liveness
+func (v *Voter) Readiness() map[string]error {
+	checks := v.Liveness()
+	checks["polyRpc"] = probe(func() error {
+		_, err := v.poly.GetCurrentBlockHeight()
+		return err
+	})
+	checks["neoRpc"] = v.probeNeo()
+	checks["db"] = v.bdb.CheckWritable()
+	return checks
+}
+
+func (v *Voter) checkBeat(beat *int64) error {
+	last := time.Unix(atomic.LoadInt64(beat), 0)
+	window := time.Duration(v.config.HealthWindow) * time.Second
+	if time.Since(last) > window {
+		return fmt.Errorf("no progress since %s", last.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// probeNeo succeeds when any neo client answers
+func (v *Voter) probeNeo() error {
+	clients := v.neo.list()
+	errs := make(chan error, len(clients))
+	for _, c := range clients {
+		c := c
+		go func() {
+			errs <- probe(func() error {
+				res := c.GetBlockCount()
+				if res.HasError() {
+					return fmt.Errorf("%s: %s", c.url, res.GetErrorInfo())
+				}
+				return nil
+			})
+		}()
+	}
+	var err error
+	for range clients {
+		if err = <-errs; err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no neo rpc reachable, last error: %v", err)
+}
+
+func probe(f func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- f()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(probeTimeout):
+		return fmt.Errorf("timeout after %s", probeTimeout)
+	}
+}
@@ -1,20 +1,25 @@
 package voter
 
 import (
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/joeqian10/neo3-gogogo/crypto"
 	"github.com/joeqian10/neo3-gogogo/helper"
 	"github.com/joeqian10/neo3-gogogo/io"
 	"github.com/joeqian10/neo3-gogogo/mpt"
-	"github.com/joeqian10/neo3-gogogo/rpc"
 	"github.com/joeqian10/neo3-gogogo/rpc/models"
+	"github.com/polynetwork/neo3-voter/audit"
 	"github.com/polynetwork/neo3-voter/common"
+	"github.com/polynetwork/neo3-voter/db"
+	"github.com/polynetwork/neo3-voter/log"
+	"github.com/polynetwork/neo3-voter/metrics"
 	pCommon "github.com/polynetwork/poly/common"
 	hsCommon "github.com/polynetwork/poly/native/service/header_sync/common"
 	"github.com/polynetwork/poly/native/service/header_sync/neo"
 	polyUtils "github.com/polynetwork/poly/native/service/utils"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -38,6 +43,9 @@ RPC:
 	response := c.GetBlockCount()
 	if response.HasError() {
 		Log.Errorf("GetBlockCount error: %s, client: %s", response.GetErrorInfo(), c.Endpoint.String())
+		if !sleep(v.ctx, time.Second) {
+			return 0
+		}
 		goto RPC
 	}
 	startHeight = uint32(response.Result - 1)
@@ -47,52 +55,109 @@ RPC:
 func (v *Voter) monitorNeo() {
 
 	nextHeight := v.getNeoStartHeight()
+	var replayed time.Time
 
-	for {
-		c := v.chooseClient()
-		response := c.GetBlockCount()
-		if response.HasError() {
-			Log.Warnf("GetBlockCount failed: %s", response.GetErrorInfo())
-			sleep()
+	for v.ctx.Err() == nil {
+		height, err := v.neoHeight()
+		if err != nil {
+			Log.Warnf("%v", err)
+			sleep(v.ctx, time.Second)
 			continue
 		}
-		height := uint32(response.Result - 1)
+		metrics.SetHeights(metrics.ChainNeo, height, nextHeight)
 		if height < nextHeight+NeoUsefulBlockNum {
-			sleep()
+			// idle at the tip counts as progress, a block failing again does not
+			v.beatNeo()
+			if v.replayDue(&replayed) {
+				v.replayNeoEvents()
+			}
+			v.waitNeo(time.Second)
 			continue
 		}
 
-		for nextHeight < height-NeoUsefulBlockNum {
-			Log.Infof("process neo height: %d", nextHeight)
-			err := v.fetchLockDepositEvents(nextHeight)
+		// blocks are fetched ahead but voted strictly in height order, a
+		// cancelled block is left unchecked so it is replayed on restart
+		end := height - NeoUsefulBlockNum
+		ctx, cancel := context.WithCancel(v.ctx)
+		for item := range v.prefetchNeo(ctx, nextHeight, end) {
+			block := item.(*neoBlock)
+			if block.err != nil {
+				Log.Warnf("fetchNeoBlock %d failed:%v", block.height, block.err)
+				sleep(v.ctx, time.Second)
+				break
+			}
+			Log.WithFields(log.Fields{"chain": metrics.ChainNeo, "height": nextHeight}).Infof("process neo height: %d", nextHeight)
+			if err = v.voteNeoBlock(block); err != nil {
+				Log.Warnf("voteNeoBlock failed:%v", err)
+				sleep(v.ctx, time.Second)
+				break
+			}
+			// only checkpoint once every vote of the block is committed
+			err = v.bdb.PutNeoHeight(nextHeight + 1)
 			if err != nil {
-				Log.Warnf("fetchLockDepositEvents failed:%v", err)
-				sleep()
-				continue
+				Log.Warnf("PutNeoHeight failed:%v", err)
+				sleep(v.ctx, time.Second)
+				break
 			}
 			nextHeight++
+			metrics.SetHeights(metrics.ChainNeo, height, nextHeight)
+			v.beatNeo()
+		}
+		cancel()
+		if nextHeight >= end {
+			v.waitNeo(v.settings().neoInterval)
 		}
-		time.Sleep(time.Second * 2)
 	}
+	Log.Infof("monitorNeo stopped at height: %d", nextHeight)
 }
 
-func (v *Voter) fetchLockDepositEvents(height uint32) error {
+// neoHeight is the latest block index, from the stream while it is up
+func (v *Voter) neoHeight() (uint32, error) {
+	if tip, ok := v.stream.current(); ok {
+		return tip, nil
+	}
 	c := v.chooseClient()
+	response := c.GetBlockCount()
+	if response.HasError() {
+		return 0, fmt.Errorf("GetBlockCount failed: %s", response.GetErrorInfo())
+	}
+	return uint32(response.Result - 1), nil
+}
+
+// waitNeo sleeps d while polling, with the stream up it waits for its next
+// event instead
+func (v *Voter) waitNeo(d time.Duration) {
+	if _, ok := v.stream.current(); !ok {
+		sleep(v.ctx, d)
+		return
+	}
+	select {
+	case <-v.stream.wake:
+	case <-time.After(neoStreamIdle):
+	case <-v.ctx.Done():
+	}
+}
+
+// fetchNeoBlock reads the CrossChainLockEvents of a block from c, it only
+// reads so blocks ahead of the vote cursor can be fetched concurrently
+func (v *Voter) fetchNeoBlock(c *neoClient, height uint32) (*neoBlock, error) {
 	blockResponse := c.GetBlock(strconv.Itoa(int(height)))
 	if blockResponse.HasError() {
-		return fmt.Errorf("neoSdk.GetBlockByIndex error: %s", blockResponse.GetErrorInfo())
+		return nil, fmt.Errorf("neoSdk.GetBlockByIndex error: %s", blockResponse.GetErrorInfo())
 	}
 	blk := blockResponse.Result
 	if blk.Hash == "" {
-		return fmt.Errorf("neoSdk.GetBlockByIndex error: empty block")
+		return nil, fmt.Errorf("neoSdk.GetBlockByIndex error: empty block")
 	}
 
+	block := &neoBlock{height: height}
+	n2pContract := v.settings().n2pContract
 	txs := blk.Tx
 	for _, tx := range txs {
 		// check tx script is useless since which contract calling ccmc is not sure
 		response := c.GetApplicationLog(tx.Hash)
 		if response.HasError() {
-			return fmt.Errorf("neoSdk.GetApplicationLog error: %s", response.GetErrorInfo())
+			return nil, fmt.Errorf("neoSdk.GetApplicationLog error: %s", response.GetErrorInfo())
 		}
 
 		for _, execution := range response.Result.Executions {
@@ -101,24 +166,24 @@ func (v *Voter) fetchLockDepositEvents(height uint32) error {
 			}
 			notifications := execution.Notifications
 			// this loop confirm tx is a cross chain tx
-			for _, notification := range execution.Notifications {
+			for i, notification := range execution.Notifications {
 				u, _ := helper.UInt160FromString(notification.Contract)
 				if "0x"+u.String() == v.config.NeoConfig.CCMC && notification.EventName == "CrossChainLockEvent" {
 					if notification.State.Type != "Array" {
-						return fmt.Errorf("notification.State.Type error: Type is not Array")
+						return nil, fmt.Errorf("notification.State.Type error: Type is not Array")
 					}
 					notification.State.Convert() // Type == "Array"
 					// convert to []InvokeStack
 					states := notification.State.Value.([]models.InvokeStack)
 					if len(states) != 5 {
-						return fmt.Errorf("notification.State.Value error: Wrong length of states")
+						return nil, fmt.Errorf("notification.State.Value error: Wrong length of states")
 					}
 					// when empty, relay everything
-					if v.config.NeoConfig.N2PContract != "" {
+					if n2pContract != "" {
 						// this loop check it is for this specific contract
 						for index, ntf := range notifications {
 							nc, _ := helper.UInt160FromString(ntf.Contract)
-							if "0x"+nc.String() != v.config.NeoConfig.N2PContract {
+							if "0x"+nc.String() != n2pContract {
 								if index < len(notifications)-1 {
 									continue
 								}
@@ -132,51 +197,110 @@ func (v *Voter) fetchLockDepositEvents(height uint32) error {
 					key := states[3].Value.(string)       // base64 string for storeKey: 0102 + toChainId + toRequestId, like 01020501
 					temp, err := crypto.Base64Decode(key) // base64 encoded
 					if err != nil {
-						return fmt.Errorf("base64decode key error: %s", err)
-					}
-					key = helper.BytesToHex(temp)
-					//get relay chain sync height
-					latestSyncHeight, err := v.GetLatestSyncHeightOnPoly(v.config.NeoConfig.SideChainId)
-					if err != nil {
-						return fmt.Errorf("GetCurrentRelayChainSyncHeight error: %s", err)
-					}
-					var passed uint32
-					if height >= latestSyncHeight {
-						passed = height
-					} else {
-						passed = latestSyncHeight
-					}
-					Log.Infof("process neo tx: " + tx.Hash)
-					txHash, err := v.commitVote(key, passed)
-					if err != nil {
-						Log.Errorf("--------------------------------------------------")
-						Log.Errorf("commitVote error: %s", err)
-						Log.Errorf("neoHeight: %d, neoTxHash: %s", height, tx.Hash)
-						Log.Errorf("--------------------------------------------------")
-						return err
-					}
-					if txHash == EMPTY {
-						continue
-					}
-					err = v.waitTx(txHash)
-					if err != nil {
-						Log.Errorf("waitTx failed: %v, txHash: %s", err, txHash)
-						return err
+						return nil, fmt.Errorf("base64decode key error: %s", err)
 					}
+					block.events = append(block.events, neoLockEvent{txHash: tx.Hash, index: i, key: helper.BytesToHex(temp)})
 				}
 			NEXT:
 			} // notification
 		} // execution
 	}
+	return block, nil
+}
+
+// voteNeoBlock commits a vote for every lock event of the block, one after
+// the other
+func (v *Voter) voteNeoBlock(block *neoBlock) (err error) {
+	height := block.height
+	for _, ev := range block.events {
+		evKey := db.NeoEventKey(ev.txHash, ev.index)
+		evLog := Log.WithFields(log.Fields{"chain": metrics.ChainNeo, "height": height, "tx": ev.txHash, "index": ev.index})
+		record := v.bdb.GetNeoEvent(evKey)
+		if record != nil && record.Status == db.EventConfirmed {
+			evLog.WithFields(log.Fields{"phase": "skip"}).Infof("neo tx %s notification %d already confirmed, skip", ev.txHash, ev.index)
+			continue
+		}
+		if v.tracked(record) {
+			evLog.WithFields(log.Fields{"phase": "skip"}).Infof("neo tx %s notification %d waits in poly tx %s, skip", ev.txHash, ev.index, record.PolyTxHash)
+			continue
+		}
+		passed, err := v.voteHeight(height)
+		if err != nil {
+			return err
+		}
+		evLog.WithFields(log.Fields{"phase": "vote"}).Infof("process neo tx: " + ev.txHash)
+		if err = v.bdb.PutNeoEvent(evKey, height, db.EventPending, EMPTY); err != nil {
+			return fmt.Errorf("PutNeoEvent error: %s", err)
+		}
+		// keep the same client so state root and proof come from one node
+		c := v.chooseClient()
+		txHash, crossChainId, err := v.commitVote(c, ev.txHash, ev.key, passed)
+		// a bad proof is never sent to poly, ask the next endpoint instead
+		for tries := 1; errors.Is(err, errInvalidProof) && tries < len(v.neo.list()); tries++ {
+			c.penalize()
+			c = v.neo.chooseExcept(c)
+			evLog.WithFields(log.Fields{"phase": "proof", "rpc": c.url}).Warnf("retry proof of neo tx %s on %s", ev.txHash, c.url)
+			txHash, crossChainId, err = v.commitVote(c, ev.txHash, ev.key, passed)
+		}
+		switch {
+		case err == nil:
+		case settled(err):
+			evLog.WithFields(log.Fields{"phase": "vote"}).Infof("neo tx %s: %v", ev.txHash, err)
+			v.putNeoEvent(evKey, height, db.EventConfirmed, EMPTY)
+			continue
+		case hopeless(err):
+			// poly rejects it, alert and go on with the block, replayNeoEvents tries it again
+			observePolyError(metrics.KindVote, err)
+			metrics.IncSubmission(metrics.KindVote, metrics.ResultFailed)
+			evLog.WithFields(log.Fields{"phase": "vote", "error": err}).Errorf("commitVote error: %s, neoHeight: %d, neoTxHash: %s, replayed later", err, height, ev.txHash)
+			// the block is voted again unless the replay can find the event
+			if err = v.bdb.PutNeoEvent(evKey, height, db.EventFailed, EMPTY); err != nil {
+				return fmt.Errorf("PutNeoEvent error: %s", err)
+			}
+			continue
+		default:
+			// the block is voted again once poly or neo catch up
+			observePolyError(metrics.KindVote, err)
+			evLog.WithFields(log.Fields{"phase": "vote", "error": err}).Warnf("commitVote error: %s, neoHeight: %d, neoTxHash: %s", err, height, ev.txHash)
+			return err
+		}
+		metrics.IncSubmission(metrics.KindVote, metrics.ResultSubmitted)
+		err = v.trackPolyTx(txHash, &db.PolyTxRecord{
+			Kind:         db.PolyTxVote,
+			EventKey:     string(evKey),
+			Height:       height,
+			NeoTxHash:    ev.txHash,
+			StorageKey:   ev.key,
+			CrossChainId: hex.EncodeToString(crossChainId),
+		})
+		if err != nil {
+			return fmt.Errorf("trackPolyTx error: %s", err)
+		}
+		v.putNeoEvent(evKey, height, db.EventSubmitted, txHash)
+	}
 	return nil
 }
 
+// voteHeight is the height a vote for a neo block claims, not below what poly
+// has synced of neo
+func (v *Voter) voteHeight(height uint32) (uint32, error) {
+	//get relay chain sync height
+	latestSyncHeight, err := v.GetLatestSyncHeightOnPoly(v.config.NeoConfig.SideChainId)
+	if err != nil {
+		return 0, fmt.Errorf("GetCurrentRelayChainSyncHeight error: %s", err)
+	}
+	if height >= latestSyncHeight {
+		return height, nil
+	}
+	return latestSyncHeight, nil
+}
+
 // GetLatestSyncHeightOnPoly :get the synced NEO blockHeight from poly
 func (v *Voter) GetLatestSyncHeightOnPoly(neoChainID uint64) (uint32, error) {
 	contractAddress := polyUtils.HeaderSyncContractAddress
 	neoChainIDBytes := common.GetUint64Bytes(neoChainID)
 	key := common.ConcatKey([]byte(hsCommon.CONSENSUS_PEER), neoChainIDBytes)
-	value, err := v.polySdk.ClientMgr.GetStorage(contractAddress.ToHexString(), key)
+	value, err := v.poly.GetStorage(contractAddress.ToHexString(), key)
 	if err != nil {
 		return 0, fmt.Errorf("getStorage error: %s", err)
 	}
@@ -190,17 +314,18 @@ func (v *Voter) GetLatestSyncHeightOnPoly(neoChainID uint64) (uint32, error) {
 	return height, nil
 }
 
-func (v *Voter) commitVote(key string, height uint32) (string, error) {
+func (v *Voter) commitVote(c *neoClient, neoTxHash, key string, height uint32) (txHash string, crossChainId []byte, err error) {
+	// monitorNeo and the tracker share the state root cursor
+	v.voteMu.Lock()
+	defer v.voteMu.Unlock()
 
-	c := v.chooseClient()
-	//get current state height
-	var stateHeight uint32 = 0
-	for stateHeight < height {
-		res := c.GetStateHeight()
-		if res.HasError() {
-			return EMPTY, fmt.Errorf("neoSdk.GetStateHeight error: %s", res.GetErrorInfo())
-		}
-		stateHeight = res.Result.ValidateRootIndex
+	//get current state height, the vote waits outside the lock until it is validated
+	res := c.GetStateHeight()
+	if res.HasError() {
+		return EMPTY, nil, fmt.Errorf("neoSdk.GetStateHeight error: %s", res.GetErrorInfo())
+	}
+	if stateHeight := res.Result.ValidateRootIndex; stateHeight < height {
+		return EMPTY, nil, fmt.Errorf("%w: %d < %d on %s", errStateHeight, stateHeight, height, c.url)
 	}
 
 	// get state root
@@ -215,14 +340,13 @@ func (v *Voter) commitVote(key string, height uint32) (string, error) {
 	for !srGot {
 		res2 := c.GetStateRoot(height2)
 		if res2.HasError() {
-			return EMPTY, fmt.Errorf("neoSdk.GetStateRootByIndex error: %s", res2.GetErrorInfo())
+			return EMPTY, nil, fmt.Errorf("neoSdk.GetStateRootByIndex error: %s", res2.GetErrorInfo())
 		}
 		stateRoot = res2.Result
 		if len(stateRoot.Witnesses) == 0 { // no witness
 			height2++
 		} else {
 			srGot = true
-			v.neoStateRootHeight = height2 // next tx can start from this height to get state root
 		}
 	}
 	buff := io.NewBufBinaryWriter()
@@ -233,42 +357,67 @@ func (v *Voter) commitVote(key string, height uint32) (string, error) {
 	// get proof
 	res3 := c.GetProof(stateRoot.RootHash, v.config.NeoConfig.CCMC, crypto.Base64Encode(helper.HexToBytes(key)))
 	if res3.HasError() {
-		return EMPTY, fmt.Errorf("neoSdk.GetProof error: %s", res3.Error.Message)
+		return EMPTY, nil, fmt.Errorf("neoSdk.GetProof error: %s", res3.Error.Message)
 	}
 	proof, err := crypto.Base64Decode(res3.Result)
 	if err != nil {
-		return EMPTY, fmt.Errorf("decode proof error: %s", err)
+		return EMPTY, nil, fmt.Errorf("decode proof error: %s", err)
 	}
 	//Log.Info("proof: %s", helper.BytesToHex(proof))
 
-	// following is for testing only
-	//id, k, proofs, err := mpt.ResolveProof(proof)
-	//root, _ := helper.UInt256FromString(stateRoot.RootHash)
-	//value, err := mpt.VerifyProof(root, id, k, proofs)
-	//Log.Infof("value: %s", helper.BytesToHex(value))
+	// verify the proof before poly sees it
+	contractId, err := v.getCcmcId(c)
+	if err != nil {
+		return EMPTY, nil, err
+	}
+	param, err := verifyNeoProof(stateRoot.RootHash, contractId, helper.HexToBytes(key), proof)
+	if err != nil {
+		Log.Errorf("proof incident: rpc=%s key=%s stateRootIndex=%d rootHash=%s proof=%s reason=%v",
+			c.url, key, stateRoot.Index, stateRoot.RootHash, helper.BytesToHex(proof), err)
+		metrics.NeoProofIncidents.WithLabelValues(c.url).Inc()
+		return EMPTY, nil, err
+	}
+	v.neoStateRootHeight = height2 // next tx can start from this height to get state root
+	metrics.NeoStateRootHeight.Set(float64(height2))
+
+	// no vote leaves the voter without its audit record
+	vote := &audit.Record{
+		Kind:           audit.KindVote,
+		NeoTxHash:      neoTxHash,
+		StorageKey:     key,
+		StateRootIndex: stateRoot.Index,
+	}
+	if err = v.audit.Append(vote); err != nil {
+		return EMPTY, nil, fmt.Errorf("audit vote of neo tx %s: %v", neoTxHash, err)
+	}
 
 	//sending SyncProof transaction to
-	txHash, err := v.polySdk.Native.Ccm.ImportOuterTransfer(
+	relayer := v.signer.Address()
+	hash, err := v.poly.ImportOuterTransfer(
 		v.config.NeoConfig.SideChainId,
 		nil,
 		height,
 		proof,
-		v.signer.Address[:],
+		relayer[:],
 		crossChainMsg,
 		v.signer)
-	if err != nil {
-		if strings.Contains(err.Error(), "checkDoneTx, tx already done") {
-			Log.Infof("ImportOuterTransfer: %s", err.Error())
-			return EMPTY, nil
-		} else {
-			return EMPTY, fmt.Errorf("ImportOuterTransfer error: %s, crossChainMsg: %s, proof: %s", err, helper.BytesToHex(crossChainMsg), helper.BytesToHex(proof))
+	if err = classifyPolyError(err); err != nil {
+		if settled(err) {
+			return EMPTY, nil, err
 		}
+		return EMPTY, nil, fmt.Errorf("ImportOuterTransfer error: %w, crossChainMsg: %s, proof: %s", err, helper.BytesToHex(crossChainMsg), helper.BytesToHex(proof))
 	}
 
-	return txHash.ToHexString(), nil
+	v.auditSent(vote, hash.ToHexString())
+	return hash.ToHexString(), param.CrossChainID, nil
+}
+
+func (v *Voter) putNeoEvent(key []byte, height uint32, status db.EventStatus, polyTxHash string) {
+	if err := v.bdb.PutNeoEvent(key, height, status, polyTxHash); err != nil {
+		Log.Warnf("PutNeoEvent %s %s failed:%v", key, status, err)
+	}
 }
 
-func (v *Voter) chooseClient() *rpc.RpcClient {
-	v.idx = randIdx(len(v.clients))
-	return v.clients[v.idx]
+func (v *Voter) chooseClient() *neoClient {
+	return v.neo.choose()
 }
@@ -1,10 +1,16 @@
 package voter
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
-	"github.com/polynetwork/poly-go-sdk/common"
-	common1 "github.com/polynetwork/poly/common"
-	common2 "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+	"errors"
+	"fmt"
+	"github.com/polynetwork/neo3-voter/audit"
+	"github.com/polynetwork/neo3-voter/db"
+	"github.com/polynetwork/neo3-voter/log"
+	"github.com/polynetwork/neo3-voter/metrics"
+	"github.com/polynetwork/neo3-voter/policy"
 	"time"
 )
 
@@ -21,127 +27,259 @@ func (v *Voter) getPolyStartHeight() (startHeight uint32) {
 		return
 	}
 
-	startHeight, err := v.polySdk.GetCurrentBlockHeight()
-	if err != nil {
-		Log.Fatalf("polySdk.GetCurrentBlockHeight failed:%v", err)
+	for {
+		height, err := v.poly.GetCurrentBlockHeight()
+		if err == nil {
+			return height
+		}
+		Log.Errorf("polySdk.GetCurrentBlockHeight failed:%v", err)
+		if !sleep(v.ctx, time.Second) {
+			return 0
+		}
 	}
-	return
 }
 
 func (v *Voter) monitorPoly() {
 
 	nextHeight := v.getPolyStartHeight()
+	var replayed time.Time
 
-	for {
-		height, err := v.polySdk.GetCurrentBlockHeight()
+	for v.ctx.Err() == nil {
+		height, err := v.poly.GetCurrentBlockHeight()
 		if err != nil {
 			Log.Errorf("monitorPoly GetCurrentBlockHeight failed:%v", err)
+			sleep(v.ctx, time.Second)
 			continue
 		}
 		height--
+		metrics.SetHeights(metrics.ChainPoly, height, nextHeight)
 		if height < nextHeight+PolyUsefulBlockNum {
 			//Log.Infof("monitorPoly height(%d) < nextHeight(%d)+POLY_USEFUL_BLOCK_NUM(%d)", height, nextHeight, PolyUsefulBlockNum)
+			// idle at the tip counts as progress, a block failing again does not
+			v.beatPoly()
+			if v.replayDue(&replayed) {
+				v.replayPolyEvents()
+			}
+			sleep(v.ctx, time.Second)
 			continue
 		}
 
-		for nextHeight < height-PolyUsefulBlockNum {
-			Log.Infof("handling poly height:%d", nextHeight)
-			err = v.handleMakeTxEvents(nextHeight)
-			if err != nil {
-				Log.Warnf("fetchLockDepositEvents failed:%v", err)
-				sleep()
-				continue
+		// blocks and proofs are fetched ahead, signatures are sent in height
+		// order and confirmed by the tracker
+		end := height - PolyUsefulBlockNum
+		ctx, cancel := context.WithCancel(v.ctx)
+		for item := range v.prefetchPoly(ctx, nextHeight, end) {
+			block := item.(*polyBlock)
+			if block.err != nil {
+				Log.Warnf("scanPolyBlock %d failed:%v", block.height, block.err)
+				sleep(v.ctx, time.Second)
+				break
+			}
+			Log.WithFields(log.Fields{"chain": metrics.ChainPoly, "height": nextHeight}).Infof("handling poly height:%d", nextHeight)
+			if err = v.handleMakeTxEvents(block); err != nil {
+				Log.Warnf("handleMakeTxEvents failed:%v", err)
+				sleep(v.ctx, time.Second)
+				break
 			}
 			nextHeight++
+			metrics.SetHeights(metrics.ChainPoly, height, nextHeight)
+			v.beatPoly()
 		}
+		cancel()
 		Log.Infof("monitorPoly nextHeight:%d", nextHeight)
 		err = v.bdb.PutPolyHeight(nextHeight)
 		if err != nil {
 			Log.Warnf("PutPolyHeight failed:%v", err)
 		}
-		time.Sleep(time.Second * 2)
+		if nextHeight >= end {
+			sleep(v.ctx, v.settings().polyInterval)
+		}
 	}
+	Log.Infof("monitorPoly stopped at height: %d", nextHeight)
 }
 
-func (v *Voter) handleMakeTxEvents(height uint32) (err error) {
-
-	hdr, err := v.polySdk.GetHeaderByHeight(height + 1)
-	if err != nil {
-		return
-	}
-	events, err := v.polySdk.GetSmartContractEventByBlock(height)
-	if err != nil {
-		return
-	}
+// handleMakeTxEvents signs the proven makeProof values of a block and hands
+// the signature txs to the tracker
+func (v *Voter) handleMakeTxEvents(block *polyBlock) (err error) {
+	height := block.height
+	verified := false
+	for _, mp := range block.proofs {
+		evKey := db.PolyEventKey(mp.key)
+		evLog := Log.WithFields(log.Fields{"chain": metrics.ChainPoly, "height": height, "tx": mp.txHash, "key": mp.key})
+		if record := v.bdb.GetPolyEvent(evKey); v.tracked(record) {
+			evLog.WithFields(log.Fields{"phase": "skip"}).Infof("makeProof key %s waits in poly tx %s, skip", mp.key, record.PolyTxHash)
+			continue
+		}
+		// never sign for a header the poly keepers did not sign
+		if !verified {
+			if err = v.verifyPolyHeader(block.hdr); err != nil {
+				evLog.WithFields(log.Fields{"phase": "verify", "error": err}).Errorf("handleMakeTxEvents - verifyPolyHeader failed:%v", err)
+				return
+			}
+			verified = true
+		}
+		if v.approved(mp) {
+			evLog.WithFields(log.Fields{"phase": "policy"}).Infof("handleMakeTxEvents - key %s released by the operator, sign policy skipped", mp.key)
+		} else if err = v.policy.Check(mp.param); err != nil {
+			var violation *policy.Violation
+			if !errors.As(err, &violation) {
+				evLog.WithFields(log.Fields{"phase": "policy", "error": err}).Warnf("handleMakeTxEvents - key %s: %v", mp.key, err)
+				return
+			}
+			evLog.WithFields(log.Fields{"phase": "policy", "error": err}).Errorf("handleMakeTxEvents - quarantine key %s: %v", mp.key, err)
+			metrics.IncSubmission(metrics.KindSignature, metrics.ResultRejected)
+			err = v.bdb.PutQuarantine(mp.key, &db.QuarantineRecord{
+				PolyHeight: height,
+				Value:      hex.EncodeToString(mp.value),
+				Reason:     violation.Reason,
+			})
+			if err != nil {
+				Log.Errorf("PutQuarantine failed:%v", err)
+				return
+			}
+			continue
+		}
+		// nothing to sign if poly has our signature or a quorum already
+		if err = v.signatureStatus(mp.value); err != nil {
+			if !settled(err) {
+				evLog.WithFields(log.Fields{"phase": "sign", "error": err}).Warnf("signatureStatus failed:%v", err)
+				return
+			}
+			evLog.WithFields(log.Fields{"phase": "skip"}).Infof("makeProof key %s: %v", mp.key, err)
+			v.putPolyEvent(evKey, height, db.EventConfirmed, EMPTY)
+			err = nil
+			continue
+		}
+		// sign toMerkleValue
+		var sig []byte
+		sig, err = v.signForNeo(mp.value)
+		if err != nil {
+			evLog.WithFields(log.Fields{"phase": "sign", "error": err}).Errorf("signForNeo failed:%v", err)
+			return
+		}
+		// no signature leaves the voter without its audit record
+		subjectHash := sha256.Sum256(mp.value)
+		signed := &audit.Record{
+			Kind:         audit.KindSignature,
+			SubjectHash:  hex.EncodeToString(subjectHash[:]),
+			Signature:    hex.EncodeToString(sig),
+			PolyHeight:   height,
+			MakeProofKey: mp.key,
+		}
+		if err = v.audit.Append(signed); err != nil {
+			evLog.WithFields(log.Fields{"phase": "sign", "error": err}).Errorf("audit signature failed:%v", err)
+			return
+		}
 
-	empty := true
-
-	for _, event := range events {
-		for _, notify := range event.Notify {
-			if notify.ContractAddress == v.config.PolyConfig.EntranceContractAddress {
-				states := notify.States.([]interface{})
-				method, _ := states[0].(string)
-				if method != "makeProof" {
-					continue
-				}
-
-				if uint64(states[2].(float64)) != v.config.NeoConfig.SideChainId {
-					continue
-				}
-				empty = false
-				var proof *common.MerkleProof
-				proof, err = v.polySdk.GetCrossStatesProof(hdr.Height-1, states[5].(string))
-				if err != nil {
-					Log.Errorf("handleMakeTxEvents - failed to get proof for key %s: %v", states[5].(string), err)
-					return
-				}
-				auditpath, _ := hex.DecodeString(proof.AuditPath)
-				value, _, _, _ := parseAuditpath(auditpath)
-				param := &common2.ToMerkleValue{}
-				if err = param.Deserialization(common1.NewZeroCopySource(value)); err != nil {
-					Log.Errorf("handleDepositEvents - failed to deserialize MakeTxParam (value: %x, err: %v)", value, err)
-					return
-				}
-				// sign toMerkleValue
-				var sig []byte
-				sig, err = v.signForNeo(value)
-				if err != nil {
-					Log.Errorf("signForNeo failed:%v", err)
-					return
-				}
-
-				var txHash string
-				txHash, err = v.commitSig(height, value, sig)
-				if err != nil {
-					Log.Errorf("signForNeo failed:%v", err)
-					return
-				}
-				err = v.waitTx(txHash)
-				if err != nil {
-					Log.Errorf("handleMakeTxEvents failed:%v", err)
-					return
-				}
+		if err = v.bdb.PutPolyEvent(evKey, height, db.EventPending, EMPTY); err != nil {
+			Log.Errorf("PutPolyEvent failed:%v", err)
+			return
+		}
+		if err = v.waitPolySlot(); err != nil {
+			return
+		}
+		var txHash string
+		txHash, err = v.commitSig(height, mp.value, sig, signed)
+		switch {
+		case err == nil:
+		case hopeless(err):
+			observePolyError(metrics.KindSignature, err)
+			metrics.IncSubmission(metrics.KindSignature, metrics.ResultFailed)
+			evLog.WithFields(log.Fields{"phase": "commit", "error": err}).Errorf("commitSig failed:%v, replayed later", err)
+			// the block is handled again unless the replay can find the event
+			if err = v.bdb.PutPolyEvent(evKey, height, db.EventFailed, EMPTY); err != nil {
+				Log.Errorf("PutPolyEvent failed:%v", err)
+				return
 			}
+			continue
+		default:
+			// the block is handled again, the event stays pending
+			observePolyError(metrics.KindSignature, err)
+			evLog.WithFields(log.Fields{"phase": "commit", "error": err}).Warnf("commitSig failed:%v", err)
+			return
+		}
+		v.policy.Signed()
+		metrics.IncSubmission(metrics.KindSignature, metrics.ResultSubmitted)
+		err = v.trackPolyTx(txHash, &db.PolyTxRecord{
+			Kind:      db.PolyTxSignature,
+			EventKey:  string(evKey),
+			Height:    height,
+			Subject:   hex.EncodeToString(mp.value),
+			Signature: hex.EncodeToString(sig),
+			AuditSeq:  signed.Seq,
+			AuditHash: signed.Hash,
+		})
+		if err != nil {
+			Log.Errorf("trackPolyTx failed:%v", err)
+			return
 		}
+		v.putPolyEvent(evKey, height, db.EventSubmitted, txHash)
 	}
 
-	Log.Infof("poly height %d empty: %v", height, empty)
+	Log.Infof("poly height %d empty: %v", height, len(block.proofs) == 0)
 	return
 }
 
+// approved reports whether an operator released the quarantined value of mp,
+// the approval does not cover another value under the same key
+func (v *Voter) approved(mp polyMakeProof) bool {
+	record := v.bdb.GetQuarantine(mp.key)
+	return record != nil && record.Approved && record.Value == hex.EncodeToString(mp.value)
+}
+
+// waitPolySlot holds the scan while MaxInFlight signatures wait in the tracker
+func (v *Voter) waitPolySlot() error {
+	for {
+		n, err := v.polyTxsInFlight(db.PolyTxSignature)
+		if err != nil {
+			return err
+		}
+		if uint64(n) < v.config.PolyConfig.MaxInFlight {
+			return nil
+		}
+		if !sleep(v.ctx, time.Second) {
+			return v.ctx.Err()
+		}
+	}
+}
+
+func (v *Voter) putPolyEvent(key []byte, height uint32, status db.EventStatus, polyTxHash string) {
+	if err := v.bdb.PutPolyEvent(key, height, status, polyTxHash); err != nil {
+		Log.Warnf("PutPolyEvent %s %s failed:%v", key, status, err)
+	}
+}
+
 func (v *Voter) signForNeo(data []byte) (sig []byte, err error) {
-	sig, err = v.pair.Sign(data)
+	sig, err = v.signer.SignForNeo(data)
 	return
 }
 
-func (v *Voter) commitSig(height uint32, subject, sig []byte) (txHash string, err error) {
+// commitSig sends sig to poly, the audit record signed gets a follow up with
+// the tx hash
+func (v *Voter) commitSig(height uint32, subject, sig []byte, signed *audit.Record) (txHash string, err error) {
 
-	hash, err := v.polySdk.Native.Sm.AddSignature(v.config.NeoConfig.SideChainId, subject, sig, v.signer)
-	if err != nil {
+	hash, err := v.poly.AddSignature(v.config.NeoConfig.SideChainId, subject, sig, v.signer)
+	if err = classifyPolyError(err); err != nil {
+		err = fmt.Errorf("AddSignature error: %w", err)
 		return
 	}
 
 	txHash = hash.ToHexString()
 	Log.Infof("commitSig, height: %d, txhash: %s", height, txHash)
+	v.auditSent(signed, txHash)
 	return
 }
+
+// auditSent chains the poly tx hash to the audit record of what it carries,
+// the tx is out already so a failed write is only logged
+func (v *Voter) auditSent(ref *audit.Record, polyTxHash string) {
+	err := v.audit.Append(&audit.Record{
+		Kind:       audit.KindSent,
+		RefSeq:     ref.Seq,
+		RefHash:    ref.Hash,
+		PolyTxHash: polyTxHash,
+	})
+	if err != nil {
+		Log.Errorf("audit poly tx %s of record %d failed:%v", polyTxHash, ref.Seq, err)
+	}
+}
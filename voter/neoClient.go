@@ -0,0 +1,115 @@
+package voter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/joeqian10/neo3-gogogo/rpc"
+	"github.com/polynetwork/neo3-voter/metrics"
+)
+
+const neoEwmaAlpha = 0.2
+
+// neoClient wraps a neo rpc client and records per endpoint metrics and health
+type neoClient struct {
+	*rpc.RpcClient
+	url string
+
+	mu       sync.Mutex
+	latency  float64 // ewma of call latency in seconds
+	errRate  float64 // ewma of failed calls
+	failures int     // consecutive failed calls
+	height   uint32  // latest height reported by the node
+}
+
+func newNeoClient(url string) *neoClient {
+	return &neoClient{RpcClient: rpc.NewClient(url), url: url}
+}
+
+func (c *neoClient) GetBlockCount() rpc.GetBlockCountResponse {
+	start := time.Now()
+	res := c.RpcClient.GetBlockCount()
+	c.record("getblockcount", start, res.HasError())
+	if !res.HasError() {
+		c.mu.Lock()
+		c.height = uint32(res.Result - 1)
+		c.mu.Unlock()
+	}
+	return res
+}
+
+func (c *neoClient) GetBlock(hashOrIndex string) rpc.GetBlockResponse {
+	start := time.Now()
+	res := c.RpcClient.GetBlock(hashOrIndex)
+	c.record("getblock", start, res.HasError())
+	return res
+}
+
+func (c *neoClient) GetApplicationLog(txId string) rpc.GetApplicationLogResponse {
+	start := time.Now()
+	res := c.RpcClient.GetApplicationLog(txId)
+	c.record("getapplicationlog", start, res.HasError())
+	return res
+}
+
+func (c *neoClient) GetContractState(scriptHash string) rpc.GetContractStateResponse {
+	start := time.Now()
+	res := c.RpcClient.GetContractState(scriptHash)
+	c.record("getcontractstate", start, res.HasError())
+	return res
+}
+
+func (c *neoClient) GetStateHeight() rpc.GetStateHeightResponse {
+	start := time.Now()
+	res := c.RpcClient.GetStateHeight()
+	c.record("getstateheight", start, res.HasError())
+	return res
+}
+
+func (c *neoClient) GetStateRoot(blockHeight uint32) rpc.GetStateRootResponse {
+	start := time.Now()
+	res := c.RpcClient.GetStateRoot(blockHeight)
+	c.record("getstateroot", start, res.HasError())
+	return res
+}
+
+func (c *neoClient) GetProof(rootHash, contractScriptHash, storeKey string) rpc.GetProofResponse {
+	start := time.Now()
+	res := c.RpcClient.GetProof(rootHash, contractScriptHash, storeKey)
+	c.record("getproof", start, res.HasError())
+	return res
+}
+
+func (c *neoClient) record(method string, start time.Time, failed bool) {
+	metrics.ObserveNeoRpc(c.url, method, start, failed)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency = ewma(c.latency, time.Since(start).Seconds())
+	if failed {
+		c.errRate = ewma(c.errRate, 1)
+		c.failures++
+	} else {
+		c.errRate = ewma(c.errRate, 0)
+		c.failures = 0
+	}
+}
+
+// penalize takes the client out of the pool until it answers probes again
+func (c *neoClient) penalize() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = NeoMaxFailures
+	c.errRate = ewma(c.errRate, 1)
+}
+
+// stats returns a consistent snapshot of the health fields
+func (c *neoClient) stats() (latency, errRate float64, failures int, height uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.latency, c.errRate, c.failures, c.height
+}
+
+func ewma(old, sample float64) float64 {
+	return old*(1-neoEwmaAlpha) + sample*neoEwmaAlpha
+}
@@ -0,0 +1,48 @@
+package voter
+
+import (
+	"context"
+
+	"github.com/polynetwork/neo3-voter/metrics"
+)
+
+type neoLockEvent struct {
+	txHash string
+	index  int    // notification index in the execution
+	key    string // hex storage key of the cross chain tx in the CCMC
+}
+
+// neoBlock holds the lock events of a block, or why it could not be fetched
+type neoBlock struct {
+	height uint32
+	events []neoLockEvent
+	err    error
+}
+
+// prefetchNeo fetches the blocks in [from, end) ahead of the vote cursor,
+// spread over the healthy endpoints, with more workers the further behind
+func (v *Voter) prefetchNeo(ctx context.Context, from, end uint32) <-chan interface{} {
+	workers := fetchWorkers(end-from, v.config.NeoConfig.FetchWorkers)
+	metrics.FetchWorkers.WithLabelValues(metrics.ChainNeo).Set(float64(workers))
+	clients := v.neo.healthyClients()
+	return prefetch(ctx, from, end, workers, func(height uint32) interface{} {
+		if len(clients) == 0 {
+			return v.fetchNeoBlockFrom(v.chooseClient(), height)
+		}
+		return v.fetchNeoBlockFrom(clients[int(height)%len(clients)], height)
+	})
+}
+
+// fetchNeoBlockFrom asks c first and one other endpoint if c fails
+func (v *Voter) fetchNeoBlockFrom(c *neoClient, height uint32) *neoBlock {
+	block, err := v.fetchNeoBlock(c, height)
+	if err != nil {
+		if other := v.neo.chooseExcept(c); other != c {
+			block, err = v.fetchNeoBlock(other, height)
+		}
+	}
+	if err != nil {
+		return &neoBlock{height: height, err: err}
+	}
+	return block
+}
@@ -0,0 +1,157 @@
+package voter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/polynetwork/neo3-voter/metrics"
+)
+
+var (
+	NeoMaxLag            = uint32(5)
+	NeoMaxFailures       = 3
+	NeoMaxErrRate        = 0.5
+	NeoPoolProbeInterval = time.Second * 10
+)
+
+// neoPool hands out the healthiest neo client, an endpoint is dropped while it
+// keeps failing or lags behind the best known height and comes back once the
+// background probe sees it recover
+type neoPool struct {
+	mu      sync.RWMutex
+	clients []*neoClient
+}
+
+func newNeoPool(urls []string) *neoPool {
+	p := new(neoPool)
+	p.reset(urls)
+	return p
+}
+
+func (p *neoPool) list() []*neoClient {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.clients
+}
+
+// reset swaps in a new endpoint list, clients of urls that stay keep their
+// stats and clients already handed out keep working
+func (p *neoPool) reset(urls []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old := make(map[string]*neoClient)
+	for _, c := range p.clients {
+		old[c.url] = c
+	}
+	clients := make([]*neoClient, 0, len(urls))
+	for _, url := range urls {
+		if c, ok := old[url]; ok {
+			clients = append(clients, c)
+			delete(old, url)
+		} else {
+			clients = append(clients, newNeoClient(url))
+		}
+	}
+	for url := range old {
+		metrics.NeoRpcHealthy.DeleteLabelValues(url)
+	}
+	p.clients = clients
+}
+
+func (p *neoPool) bestHeight() (height uint32) {
+	for _, c := range p.list() {
+		_, _, _, h := c.stats()
+		if h > height {
+			height = h
+		}
+	}
+	return
+}
+
+func (p *neoPool) healthy(c *neoClient, best uint32) bool {
+	_, errRate, failures, height := c.stats()
+	return failures < NeoMaxFailures && errRate < NeoMaxErrRate && height+NeoMaxLag >= best
+}
+
+// healthyClients returns the clients currently in use
+func (p *neoPool) healthyClients() []*neoClient {
+	best := p.bestHeight()
+	var list []*neoClient
+	for _, c := range p.list() {
+		if p.healthy(c, best) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// choose returns the healthy client with the best score, or the best scored
+// client at all when every endpoint is unhealthy
+func (p *neoPool) choose() *neoClient {
+	return p.chooseExcept(nil)
+}
+
+// chooseExcept is choose without skip, unless skip is the only client
+func (p *neoPool) chooseExcept(skip *neoClient) *neoClient {
+	candidates := p.without(p.healthyClients(), skip)
+	if len(candidates) == 0 {
+		candidates = p.without(p.list(), skip)
+	}
+	if len(candidates) == 0 {
+		return skip
+	}
+	var best *neoClient
+	var bestScore float64
+	for _, c := range candidates {
+		latency, errRate, _, _ := c.stats()
+		score := latency * (1 + errRate*10)
+		if best == nil || score < bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
+
+func (p *neoPool) without(list []*neoClient, skip *neoClient) []*neoClient {
+	if skip == nil {
+		return list
+	}
+	res := make([]*neoClient, 0, len(list))
+	for _, c := range list {
+		if c != skip {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// probe refreshes height and health of every client
+func (p *neoPool) probe() {
+	var wg sync.WaitGroup
+	for _, c := range p.list() {
+		c := c
+		GoFunc(&wg, func() {
+			c.GetBlockCount()
+		})
+	}
+	wg.Wait()
+
+	best := p.bestHeight()
+	for _, c := range p.list() {
+		up := 0.0
+		if p.healthy(c, best) {
+			up = 1
+		}
+		metrics.NeoRpcHealthy.WithLabelValues(c.url).Set(up)
+	}
+}
+
+func (v *Voter) probeNeoPool() {
+	for {
+		v.neo.probe()
+		if !sleep(v.ctx, NeoPoolProbeInterval) {
+			return
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package voter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNeoPoolReset(t *testing.T) {
+	done := make(chan *neoPool)
+	go func() {
+		p := newNeoPool([]string{"http://a:10332", "http://b:10332"})
+		p.reset([]string{"http://b:10332", "http://c:10332"})
+		done <- p
+	}()
+	var p *neoPool
+	select {
+	case p = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reset deadlocked")
+	}
+	list := p.list()
+	if len(list) != 2 || list[0].url != "http://b:10332" || list[1].url != "http://c:10332" {
+		t.Fatalf("unexpected clients after reset: %v", list)
+	}
+}
+
+func TestNeoPoolResetKeepsClients(t *testing.T) {
+	p := newNeoPool([]string{"http://a:10332"})
+	c := p.list()[0]
+	p.reset([]string{"http://a:10332", "http://b:10332"})
+	if p.list()[0] != c {
+		t.Fatal("client of a kept url was replaced")
+	}
+}
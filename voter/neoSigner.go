@@ -0,0 +1,51 @@
+package voter
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// checkNeoSigner makes sure neo accepts signatures of our neo key, either from
+// the configured signer set or from the current poly keepers who share their
+// keys with neo by default
+func (v *Voter) checkNeoSigner() error {
+	pub := hex.EncodeToString(v.signer.NeoPublicKey().EncodePoint(true))
+	expected := v.config.NeoConfig.SignerPublicKeys
+	source := "NeoConfig.SignerPublicKeys"
+	if len(expected) == 0 {
+		height, err := v.poly.GetCurrentBlockHeight()
+		if err != nil {
+			return err
+		}
+		hdr, err := v.poly.GetHeaderByHeight(height)
+		if err != nil {
+			return err
+		}
+		info, err := polyBlockInfo(hdr)
+		if err != nil {
+			return err
+		}
+		if info.NewChainConfig == nil {
+			if hdr, err = v.poly.GetHeaderByHeight(info.LastConfigBlockNum); err != nil {
+				return err
+			}
+			if info, err = polyBlockInfo(hdr); err != nil {
+				return err
+			}
+		}
+		if info.NewChainConfig == nil {
+			return fmt.Errorf("poly header %d is not a config block", hdr.Height)
+		}
+		for _, peer := range info.NewChainConfig.Peers {
+			expected = append(expected, peer.ID)
+		}
+		source = fmt.Sprintf("poly keepers of config block %d", hdr.Height)
+	}
+	for _, key := range expected {
+		if strings.ToLower(strings.TrimPrefix(key, "0x")) == pub {
+			return nil
+		}
+	}
+	return fmt.Errorf("neo public key %s is not in %s", pub, source)
+}
@@ -0,0 +1,136 @@
+package voter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	neoStreamReadTimeout = time.Minute      // a neo block is due every 15 seconds
+	neoStreamIdle        = 30 * time.Second // longest wait for news before monitorNeo looks itself
+	neoStreamRetry       = 5 * time.Second
+)
+
+// neoStream follows new blocks and CCMC lock events over the websocket of a
+// neo-go node. It only tells monitorNeo when to look, blocks and application
+// logs still come from the rpc pool, so a missed event loses nothing.
+type neoStream struct {
+	urls []string
+	ccmc string
+
+	up   int32
+	tip  uint32
+	wake chan struct{}
+}
+
+func newNeoStream(urls []string, ccmc string) *neoStream {
+	return &neoStream{urls: urls, ccmc: ccmc, wake: make(chan struct{}, 1)}
+}
+
+type wsMessage struct {
+	Id     int
+	Method string
+	Params []json.RawMessage
+	Error  *struct {
+		Code    int
+		Message string
+	}
+}
+
+// run keeps one subscription up, trying the urls in turn
+func (s *neoStream) run(ctx context.Context) {
+	for i := 0; ctx.Err() == nil; i++ {
+		url := s.urls[i%len(s.urls)]
+		err := s.follow(ctx, url)
+		atomic.StoreInt32(&s.up, 0)
+		s.notify()
+		if ctx.Err() != nil {
+			return
+		}
+		Log.Warnf("neo stream %s dropped, polling until it is back: %v", url, err)
+		sleep(ctx, neoStreamRetry)
+	}
+}
+
+func (s *neoStream) follow(ctx context.Context, url string) error {
+	atomic.StoreUint32(&s.tip, 0)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+			conn.Close()
+		}
+	}()
+
+	subscriptions := [][]interface{}{
+		{"block_added"},
+		{"notification_from_execution", map[string]string{"contract": s.ccmc, "name": "CrossChainLockEvent"}},
+	}
+	for i, params := range subscriptions {
+		req := map[string]interface{}{"jsonrpc": "2.0", "id": i + 1, "method": "subscribe", "params": params}
+		if err = conn.WriteJSON(req); err != nil {
+			return err
+		}
+	}
+
+	subscribed := 0
+	for {
+		conn.SetReadDeadline(time.Now().Add(neoStreamReadTimeout))
+		msg := new(wsMessage)
+		if err = conn.ReadJSON(msg); err != nil {
+			return err
+		}
+		switch {
+		case msg.Error != nil:
+			return fmt.Errorf("subscribe error %d: %s", msg.Error.Code, msg.Error.Message)
+		case msg.Id > 0:
+			if subscribed++; subscribed == len(subscriptions) {
+				atomic.StoreInt32(&s.up, 1)
+				Log.Infof("neo stream %s subscribed", url)
+			}
+		case msg.Method == "block_added" && len(msg.Params) > 0:
+			var block struct{ Index uint32 }
+			if err = json.Unmarshal(msg.Params[0], &block); err != nil {
+				return fmt.Errorf("block_added: %v", err)
+			}
+			atomic.StoreUint32(&s.tip, block.Index)
+			s.notify()
+		case msg.Method == "notification_from_execution" && len(msg.Params) > 0:
+			var event struct{ Container string }
+			json.Unmarshal(msg.Params[0], &event)
+			Log.Infof("neo stream: CrossChainLockEvent in tx %s", event.Container)
+			s.notify()
+		case msg.Method == "event_missed":
+			Log.Warnf("neo stream %s missed events", url)
+			s.notify()
+		}
+	}
+}
+
+func (s *neoStream) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// current is the latest block index of the stream, ok is false while it is down
+func (s *neoStream) current() (tip uint32, ok bool) {
+	if s == nil || atomic.LoadInt32(&s.up) == 0 {
+		return 0, false
+	}
+	tip = atomic.LoadUint32(&s.tip)
+	return tip, tip > 0
+}
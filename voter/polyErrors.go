@@ -0,0 +1,157 @@
+package voter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/polynetwork/neo3-voter/common"
+	"github.com/polynetwork/neo3-voter/metrics"
+	pCommon "github.com/polynetwork/poly/common"
+	ccmCommon "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+	"github.com/polynetwork/poly/native/service/governance/signature_manager"
+	polyUtils "github.com/polynetwork/poly/native/service/utils"
+)
+
+// what the poly native contracts answer to votes and signatures
+var (
+	errAlreadyDone      = errors.New("cross chain tx already done on poly")
+	errProofRejected    = errors.New("poly rejected the neo state proof")
+	errAlreadySigned    = errors.New("subject already signed on poly")
+	errHeaderNotSynced  = errors.New("poly has not synced the neo state validators")
+	errStateHeight      = errors.New("neo state root not validated up to the vote height")
+	errNotConsensusPeer = errors.New("voter is not a poly consensus peer")
+	errWrongSigner      = errors.New("poly tx is not signed by the address it adds a signature for")
+	errPolyNode         = errors.New("poly node error")
+)
+
+// polyErrorPatterns map the messages of the cross chain manager and the
+// signature manager to their class, first match wins
+var polyErrorPatterns = []struct {
+	substr string
+	class  error
+}{
+	{"checkDoneTx, tx already done", errAlreadyDone},
+	{"VerifyFromNeoTx", errProofRejected},
+	{"VerifyNeoCrossChainProof", errProofRejected},
+	{"VerifyCrossChainMsg", errHeaderNotSynced},
+	{"verifyCrossChainMsg", errHeaderNotSynced},
+	{"GetCurrentStateValidator", errHeaderNotSynced},
+	{"consensus validator has not been initialized", errHeaderNotSynced},
+	{"signer is not consensus peer", errNotConsensusPeer},
+	{"AddSignature, checkWitness", errWrongSigner},
+}
+
+// classifyPolyError wraps err in its class so callers can use errors.Is, an
+// unknown error comes back as it is
+func classifyPolyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if isPolyNodeError(err) {
+		return fmt.Errorf("%w: %v", errPolyNode, err)
+	}
+	msg := err.Error()
+	for _, p := range polyErrorPatterns {
+		if strings.Contains(msg, p.substr) {
+			return fmt.Errorf("%w: %v", p.class, err)
+		}
+	}
+	return err
+}
+
+// polyErrorClass names the class of err for metrics and logs
+func polyErrorClass(err error) string {
+	for _, class := range []struct {
+		err  error
+		name string
+	}{
+		{errAlreadyDone, "already_done"},
+		{errAlreadySigned, "already_signed"},
+		{errProofRejected, "proof_rejected"},
+		{errInvalidProof, "invalid_proof"},
+		{errHeaderNotSynced, "header_not_synced"},
+		{errStateHeight, "state_height"},
+		{errNotConsensusPeer, "not_consensus_peer"},
+		{errWrongSigner, "wrong_signer"},
+		{errPolyNode, "node_error"},
+	} {
+		if errors.Is(err, class.err) {
+			return class.name
+		}
+	}
+	return "unknown"
+}
+
+// settled errors mean poly already has what the tx was for
+func settled(err error) bool {
+	return errors.Is(err, errAlreadyDone) || errors.Is(err, errAlreadySigned)
+}
+
+// hopeless errors do not go away by sending again right away, they are
+// alerted and the event is marked failed until the next replay
+func hopeless(err error) bool {
+	return errors.Is(err, errNotConsensusPeer) || errors.Is(err, errWrongSigner) ||
+		errors.Is(err, errProofRejected)
+}
+
+// transient errors go away once poly or neo catch up, or on another node
+func transient(err error) bool {
+	return errors.Is(err, errHeaderNotSynced) || errors.Is(err, errStateHeight) ||
+		errors.Is(err, errPolyNode) || errors.Is(err, errInvalidProof)
+}
+
+// observePolyError counts err by class and alerts the hopeless and unknown ones
+func observePolyError(kind string, err error) {
+	class := polyErrorClass(err)
+	metrics.PolyErrors.WithLabelValues(kind, class).Inc()
+	if class == "unknown" || hopeless(err) {
+		Log.Errorf("ALERT poly %s error (%s): %v", kind, class, err)
+	}
+}
+
+// signatureStatus asks the signature manager whether subject needs no more
+// signature from this voter, because it has one already or reached quorum
+func (v *Voter) signatureStatus(subject []byte) error {
+	id := sha256.Sum256(subject)
+	value, err := v.poly.GetStorage(polyUtils.SignatureManagerContractAddress.ToHexString(),
+		common.ConcatKey([]byte(signature_manager.SIG_INFO), id[:]))
+	if err != nil {
+		return classifyPolyError(err)
+	}
+	if len(value) == 0 {
+		return nil
+	}
+	info := &signature_manager.SigInfo{SigInfo: make(map[string][]byte)}
+	if err = info.Deserialization(pCommon.NewZeroCopySource(value)); err != nil {
+		return fmt.Errorf("deserialize SigInfo: %v", err)
+	}
+	address := v.signer.Address()
+	if _, ok := info.SigInfo[address.ToBase58()]; ok || info.Status {
+		return fmt.Errorf("%w: quorum %v", errAlreadySigned, info.Status)
+	}
+	return nil
+}
+
+// voteStatus asks the cross chain manager whether the cross chain tx of a vote
+// is done, crossChainId is the hex the tracker keeps and may be empty
+func (v *Voter) voteStatus(crossChainId string) error {
+	if crossChainId == "" {
+		return nil
+	}
+	id, err := hex.DecodeString(crossChainId)
+	if err != nil {
+		return fmt.Errorf("decode CrossChainId %s: %v", crossChainId, err)
+	}
+	value, err := v.poly.GetStorage(polyUtils.CrossChainManagerContractAddress.ToHexString(),
+		common.ConcatKey([]byte(ccmCommon.DONE_TX), common.GetUint64Bytes(v.config.NeoConfig.SideChainId), id))
+	if err != nil {
+		return classifyPolyError(err)
+	}
+	if len(value) > 0 {
+		return fmt.Errorf("%w: cross chain id %s", errAlreadyDone, crossChainId)
+	}
+	return nil
+}
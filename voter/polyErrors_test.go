@@ -0,0 +1,64 @@
+package voter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/polynetwork/poly-go-sdk/client"
+)
+
+func TestClassifyPolyError(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		err   error
+		class error
+		label string
+	}{
+		{"nil", nil, nil, "unknown"},
+		{"done", errors.New("[ImportExTransfer], checkDoneTx, tx already done"), errAlreadyDone, "already_done"},
+		{"proof", errors.New("VerifyFromNeoTx, verifyFromTx error: VerifyNeoCrossChainProof, verify proof error"), errProofRejected, "proof_rejected"},
+		{"validators", errors.New("neo3 MakeDepositProposal, VerifyCrossChainMsg error: GetCurrentStateValidator error"), errHeaderNotSynced, "header_not_synced"},
+		{"not peer", errors.New("AddSignature, signer is not consensus peer"), errNotConsensusPeer, "not_consensus_peer"},
+		{"wrong signer", errors.New("AddSignature, checkWitness error: validateOwner, authentication failed!"), errWrongSigner, "wrong_signer"},
+		{"node", client.PostErr{Err: errors.New("connection refused")}, errPolyNode, "node_error"},
+		{"body", errors.New("read rpc response body error:EOF"), errPolyNode, "node_error"},
+		{"unknown", errors.New("something else"), nil, "unknown"},
+	} {
+		err := classifyPolyError(tc.err)
+		if tc.class == nil {
+			if err != tc.err {
+				t.Fatalf("%s: got %v, want the error as it is", tc.name, err)
+			}
+		} else if !errors.Is(err, tc.class) {
+			t.Fatalf("%s: got %v, want class %v", tc.name, err, tc.class)
+		}
+		if label := polyErrorClass(err); label != tc.label {
+			t.Fatalf("%s: label %s, want %s", tc.name, label, tc.label)
+		}
+	}
+}
+
+func TestPolyErrorGroups(t *testing.T) {
+	for _, tc := range []struct {
+		err                          error
+		settled, hopeless, transient bool
+	}{
+		{errAlreadyDone, true, false, false},
+		{errAlreadySigned, true, false, false},
+		{errProofRejected, false, true, false},
+		{errNotConsensusPeer, false, true, false},
+		{errWrongSigner, false, true, false},
+		{errHeaderNotSynced, false, false, true},
+		{errStateHeight, false, false, true},
+		{errPolyNode, false, false, true},
+		{errInvalidProof, false, false, true},
+		{errors.New("something else"), false, false, false},
+	} {
+		// the groups must hold for wrapped errors, the way callers see them
+		err := fmt.Errorf("AddSignature error: %w", tc.err)
+		if settled(err) != tc.settled || hopeless(err) != tc.hopeless || transient(err) != tc.transient {
+			t.Fatalf("%v: settled %v hopeless %v transient %v", tc.err, settled(err), hopeless(err), transient(err))
+		}
+	}
+}
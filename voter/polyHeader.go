@@ -0,0 +1,154 @@
+package voter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/polynetwork/neo3-voter/db"
+
+	vconfig "github.com/polynetwork/poly/consensus/vbft/config"
+	"github.com/polynetwork/poly/core/signature"
+	"github.com/polynetwork/poly/core/types"
+)
+
+// polyKeepers is the poly consensus peer set announced in a config block
+type polyKeepers struct {
+	cfgHeight uint32
+	peers     map[string]struct{}
+}
+
+func polyBlockInfo(hdr *types.Header) (*vconfig.VbftBlockInfo, error) {
+	info := new(vconfig.VbftBlockInfo)
+	if err := json.Unmarshal(hdr.ConsensusPayload, info); err != nil {
+		return nil, fmt.Errorf("unmarshal consensus payload of poly header %d: %v", hdr.Height, err)
+	}
+	return info, nil
+}
+
+// signerCfgHeight returns the config block whose peers sign hdr, a config block
+// itself is still signed by the peers of the previous one
+func (v *Voter) signerCfgHeight(hdr *types.Header, info *vconfig.VbftBlockInfo) (uint32, error) {
+	cfgHeight := info.LastConfigBlockNum
+	if info.NewChainConfig != nil && hdr.Height > 0 {
+		prev, err := v.poly.GetHeaderByHeight(hdr.Height - 1)
+		if err != nil {
+			return 0, err
+		}
+		prevInfo, err := polyBlockInfo(prev)
+		if err != nil {
+			return 0, err
+		}
+		cfgHeight = prevInfo.LastConfigBlockNum
+	}
+	if cfgHeight == math.MaxUint32 { // genesis
+		cfgHeight = 0
+	}
+	return cfgHeight, nil
+}
+
+// loadPolyKeepers starts the header check from the last verified keeper set,
+// or from PolyConfig.TrustedKeepers when there is none or the config anchors a
+// later config block
+func (v *Voter) loadPolyKeepers() {
+	record := v.bdb.LatestPolyKeepers()
+	if record == nil || record.CfgHeight < v.config.PolyConfig.TrustedConfigHeight {
+		record = &db.KeepersRecord{CfgHeight: v.config.PolyConfig.TrustedConfigHeight}
+		for _, peer := range v.config.PolyConfig.TrustedKeepers {
+			record.Peers = append(record.Peers, strings.ToLower(peer))
+		}
+		if err := v.bdb.PutPolyKeepers(record); err != nil {
+			Log.Warnf("PutPolyKeepers failed:%v", err)
+		}
+	}
+	v.polyKeepers = keepersOf(record)
+	Log.Infof("poly keepers of config block %d: %d peers", record.CfgHeight, len(record.Peers))
+}
+
+func keepersOf(record *db.KeepersRecord) *polyKeepers {
+	keepers := &polyKeepers{cfgHeight: record.CfgHeight, peers: make(map[string]struct{})}
+	for _, peer := range record.Peers {
+		keepers.peers[peer] = struct{}{}
+	}
+	return keepers
+}
+
+// keepersAt returns the peer set of a config block. Sets are walked forward
+// from the trusted one, each only adopted and saved once its config block
+// verifies against the set before it.
+func (v *Voter) keepersAt(cfgHeight uint32) (*polyKeepers, error) {
+	known := v.polyKeepers
+	if known.cfgHeight == cfgHeight {
+		return known, nil
+	}
+	if cfgHeight < known.cfgHeight {
+		// a replayed event of a block before the last rotation
+		if record := v.bdb.GetPolyKeepers(cfgHeight); record != nil {
+			return keepersOf(record), nil
+		}
+		return nil, fmt.Errorf("poly config block %d is older than trusted %d", cfgHeight, known.cfgHeight)
+	}
+
+	hdr, err := v.poly.GetHeaderByHeight(cfgHeight)
+	if err != nil {
+		return nil, err
+	}
+	info, err := polyBlockInfo(hdr)
+	if err != nil {
+		return nil, err
+	}
+	if info.NewChainConfig == nil {
+		return nil, fmt.Errorf("poly header %d is not a config block", cfgHeight)
+	}
+	if err = v.verifyPolyHeader(hdr); err != nil {
+		return nil, fmt.Errorf("verify poly config block %d: %v", cfgHeight, err)
+	}
+
+	record := &db.KeepersRecord{CfgHeight: cfgHeight}
+	for _, peer := range info.NewChainConfig.Peers {
+		record.Peers = append(record.Peers, peer.ID)
+	}
+	if err = v.bdb.PutPolyKeepers(record); err != nil {
+		return nil, fmt.Errorf("PutPolyKeepers error: %v", err)
+	}
+	Log.Infof("poly keepers of config block %d verified: %d peers", cfgHeight, len(record.Peers))
+	v.polyKeepers = keepersOf(record)
+	return v.polyKeepers, nil
+}
+
+// verifyPolyHeader checks that a quorum of the poly keepers signed hdr
+func (v *Voter) verifyPolyHeader(hdr *types.Header) error {
+	info, err := polyBlockInfo(hdr)
+	if err != nil {
+		return err
+	}
+	cfgHeight, err := v.signerCfgHeight(hdr, info)
+	if err != nil {
+		return err
+	}
+	keepers, err := v.keepersAt(cfgHeight)
+	if err != nil {
+		return err
+	}
+	n := len(keepers.peers)
+	if len(hdr.Bookkeepers) < n-(n-1)/3 {
+		return fmt.Errorf("poly header %d signed by %d of %d keepers", hdr.Height, len(hdr.Bookkeepers), n)
+	}
+	seen := make(map[string]struct{})
+	for _, bookkeeper := range hdr.Bookkeepers {
+		id := vconfig.PubkeyID(bookkeeper)
+		if _, ok := keepers.peers[id]; !ok {
+			return fmt.Errorf("poly header %d signed by unknown keeper %s", hdr.Height, id)
+		}
+		if _, ok := seen[id]; ok {
+			return fmt.Errorf("poly header %d lists keeper %s twice", hdr.Height, id)
+		}
+		seen[id] = struct{}{}
+	}
+	hash := hdr.Hash()
+	if err = signature.VerifyMultiSignature(hash[:], hdr.Bookkeepers, len(hdr.Bookkeepers), hdr.SigData); err != nil {
+		return fmt.Errorf("poly header %d: %v", hdr.Height, err)
+	}
+	return nil
+}
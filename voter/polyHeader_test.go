@@ -0,0 +1,169 @@
+package voter
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/polynetwork/neo3-voter/config"
+	"github.com/polynetwork/neo3-voter/db"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly/account"
+	vconfig "github.com/polynetwork/poly/consensus/vbft/config"
+	"github.com/polynetwork/poly/core/signature"
+	"github.com/polynetwork/poly/core/types"
+)
+
+func testAccounts(n int) []*account.Account {
+	accounts := make([]*account.Account, n)
+	for i := range accounts {
+		accounts[i] = account.NewAccount("")
+	}
+	return accounts
+}
+
+func testPeerIds(accounts []*account.Account) []string {
+	ids := make([]string, len(accounts))
+	for i, acc := range accounts {
+		ids[i] = vconfig.PubkeyID(acc.PublicKey)
+	}
+	return ids
+}
+
+// testPolyHeader builds a header of a block under config block lastCfg, a
+// config block when next is set, signed by signers
+func testPolyHeader(t *testing.T, height, lastCfg uint32, next []*account.Account, signers ...*account.Account) *types.Header {
+	info := &vconfig.VbftBlockInfo{LastConfigBlockNum: lastCfg}
+	if next != nil {
+		info.NewChainConfig = &vconfig.ChainConfig{N: uint32(len(next))}
+		for i, id := range testPeerIds(next) {
+			info.NewChainConfig.Peers = append(info.NewChainConfig.Peers, &vconfig.PeerConfig{Index: uint32(i), ID: id})
+		}
+	}
+	payload, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr := &types.Header{Height: height, ConsensusPayload: payload}
+	hash := hdr.Hash()
+	for _, acc := range signers {
+		sig, err := signature.Sign(acc, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		hdr.Bookkeepers = append(hdr.Bookkeepers, acc.PublicKey)
+		hdr.SigData = append(hdr.SigData, sig)
+	}
+	return hdr
+}
+
+// newTestPolyVoter serves headers over poly json rpc and trusts keepers at
+// the genesis config block
+func newTestPolyVoter(t *testing.T, headers map[uint32]*types.Header, keepers []*account.Account) *Voter {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     string
+			Method string
+			Params []uint32
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Params) != 1 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		resp := map[string]interface{}{"id": req.Id, "error": 0, "desc": "SUCCESS"}
+		if hdr, ok := headers[req.Params[0]]; ok {
+			resp["result"] = hex.EncodeToString(hdr.ToArray())
+		} else {
+			resp["error"], resp["desc"] = 42002, "UNKNOWN BLOCK"
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+
+	pool, err := NewPolyPool([]string{server.URL}, func(polySdk *sdk.PolySdk, url string) error {
+		polySdk.NewRpcClient().SetAddress(url)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bdb, err := db.NewBoltDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(bdb.Close)
+	v := &Voter{poly: pool, bdb: bdb, config: &config.Config{
+		PolyConfig: config.PolyConfig{TrustedKeepers: testPeerIds(keepers)},
+	}}
+	v.loadPolyKeepers()
+	return v
+}
+
+func TestVerifyPolyHeader(t *testing.T) {
+	old, next, outsider := testAccounts(4), testAccounts(7), testAccounts(1)[0]
+	headers := map[uint32]*types.Header{
+		9:  testPolyHeader(t, 9, 0, nil, old...),
+		10: testPolyHeader(t, 10, 0, next, old[:3]...),
+	}
+	v := newTestPolyVoter(t, headers, old)
+
+	doubled := testPolyHeader(t, 5, 0, nil, old[:2]...)
+	doubled.Bookkeepers = append(doubled.Bookkeepers, old[0].PublicKey)
+	doubled.SigData = append(doubled.SigData, doubled.SigData[0])
+	forged := testPolyHeader(t, 5, 0, nil, old[:3]...)
+	forged.SigData[2] = testPolyHeader(t, 6, 0, nil, old[2]).SigData[0]
+
+	for _, tc := range []struct {
+		name string
+		hdr  *types.Header
+		ok   bool
+	}{
+		{"quorum", testPolyHeader(t, 5, 0, nil, old[:3]...), true},
+		{"all keepers", testPolyHeader(t, 5, 0, nil, old...), true},
+		{"under quorum", testPolyHeader(t, 5, 0, nil, old[:2]...), false},
+		{"unknown keeper", testPolyHeader(t, 5, 0, nil, old[0], old[1], outsider), false},
+		{"keeper twice", doubled, false},
+		{"signature of another header", forged, false},
+		// n-(n-1)/3 of the 7 keepers of config block 10 is 5
+		{"next keepers", testPolyHeader(t, 15, 10, nil, next[:5]...), true},
+		{"next keepers under quorum", testPolyHeader(t, 15, 10, nil, next[:4]...), false},
+		{"old keepers after rotation", testPolyHeader(t, 15, 10, nil, old...), false},
+		{"old block after rotation", testPolyHeader(t, 6, 0, nil, old[:3]...), true},
+	} {
+		err := v.verifyPolyHeader(tc.hdr)
+		if tc.ok && err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !tc.ok && err == nil {
+			t.Fatalf("%s: verified", tc.name)
+		}
+	}
+	if v.polyKeepers.cfgHeight != 10 || len(v.polyKeepers.peers) != 7 {
+		t.Fatalf("keepers of config block %d with %d peers, want 10 with 7", v.polyKeepers.cfgHeight, len(v.polyKeepers.peers))
+	}
+	if record := v.bdb.LatestPolyKeepers(); record == nil || record.CfgHeight != 10 {
+		t.Fatalf("latest saved keepers %+v, want config block 10", record)
+	}
+}
+
+func TestVerifyPolyHeaderRejectsForgedRotation(t *testing.T) {
+	old, next := testAccounts(4), testAccounts(4)
+	headers := map[uint32]*types.Header{
+		9: testPolyHeader(t, 9, 0, nil, old...),
+		// the new set announces itself, the old keepers never signed it
+		10: testPolyHeader(t, 10, 0, next, next...),
+	}
+	v := newTestPolyVoter(t, headers, old)
+
+	if err := v.verifyPolyHeader(testPolyHeader(t, 15, 10, nil, next...)); err == nil {
+		t.Fatal("header of an unverified config block verified")
+	}
+	if v.polyKeepers.cfgHeight != 0 {
+		t.Fatalf("keepers moved to config block %d", v.polyKeepers.cfgHeight)
+	}
+	if record := v.bdb.GetPolyKeepers(10); record != nil {
+		t.Fatalf("unverified keepers saved: %+v", record)
+	}
+}
@@ -0,0 +1,308 @@
+package voter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/polynetwork/neo3-voter/metrics"
+	"github.com/polynetwork/neo3-voter/signer"
+	sdk "github.com/polynetwork/poly-go-sdk"
+	"github.com/polynetwork/poly-go-sdk/client"
+	sdkcom "github.com/polynetwork/poly-go-sdk/common"
+	pCommon "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+)
+
+var (
+	PolyMaxLag            = uint32(5)
+	PolyMaxFailures       = 3
+	PolyPoolProbeInterval = time.Second * 10
+)
+
+// polyClient is one poly sdk bound to a single rpc endpoint
+type polyClient struct {
+	*sdk.PolySdk
+	url string
+
+	mu       sync.Mutex
+	failures int    // consecutive failed calls
+	height   uint32 // latest height reported by the node
+}
+
+func (c *polyClient) record(method string, start time.Time, err error) {
+	metrics.ObservePolyRpc(c.url, method, start, err != nil)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.failures++
+	} else {
+		c.failures = 0
+	}
+}
+
+func (c *polyClient) stats() (failures int, height uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failures, c.height
+}
+
+// PolyPool spreads poly calls over several endpoints, a call that fails on one
+// node is retried on the next healthy one
+type PolyPool struct {
+	chainId uint64
+
+	mu      sync.RWMutex
+	clients []*polyClient
+}
+
+// NewPolyPool binds one sdk per url, the chain id is taken from the first node
+// that answers setUp
+func NewPolyPool(urls []string, setUp func(*sdk.PolySdk, string) error) (*PolyPool, error) {
+	p := new(PolyPool)
+	var chainId uint64
+	var ready bool
+	for _, url := range urls {
+		polySdk := sdk.NewPolySdk()
+		if !ready {
+			if err := setUp(polySdk, url); err != nil {
+				Log.Warnf("set up poly %s failed: %v", url, err)
+				polySdk.NewRpcClient().SetAddress(url)
+			} else {
+				chainId, ready = polySdk.ChainId, true
+			}
+		} else {
+			polySdk.NewRpcClient().SetAddress(url)
+		}
+		p.clients = append(p.clients, &polyClient{PolySdk: polySdk, url: url})
+	}
+	if !ready {
+		return nil, fmt.Errorf("none of the poly rpc endpoints answered")
+	}
+	for _, c := range p.clients {
+		c.SetChainId(chainId)
+	}
+	p.chainId = chainId
+	return p, nil
+}
+
+// Sdk returns an sdk for offline work such as opening wallets
+func (p *PolyPool) Sdk() *sdk.PolySdk {
+	return p.list()[0].PolySdk
+}
+
+func (p *PolyPool) list() []*polyClient {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.clients
+}
+
+// reset swaps in a new endpoint list, clients of urls that stay keep their stats
+func (p *PolyPool) reset(urls []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old := make(map[string]*polyClient)
+	for _, c := range p.clients {
+		old[c.url] = c
+	}
+	clients := make([]*polyClient, 0, len(urls))
+	for _, url := range urls {
+		if c, ok := old[url]; ok {
+			clients = append(clients, c)
+			delete(old, url)
+			continue
+		}
+		polySdk := sdk.NewPolySdk()
+		polySdk.NewRpcClient().SetAddress(url)
+		polySdk.SetChainId(p.chainId)
+		clients = append(clients, &polyClient{PolySdk: polySdk, url: url})
+	}
+	for url := range old {
+		metrics.PolyRpcHealthy.DeleteLabelValues(url)
+	}
+	p.clients = clients
+}
+
+func (p *PolyPool) bestHeight() (height uint32) {
+	for _, c := range p.list() {
+		_, h := c.stats()
+		if h > height {
+			height = h
+		}
+	}
+	return
+}
+
+func (p *PolyPool) healthy(c *polyClient, best uint32) bool {
+	failures, height := c.stats()
+	return failures < PolyMaxFailures && height+PolyMaxLag >= best
+}
+
+// ordered returns healthy clients first, keeping the configured order otherwise
+func (p *PolyPool) ordered() []*polyClient {
+	best := p.bestHeight()
+	clients := p.list()
+	list := make([]*polyClient, 0, len(clients))
+	var down []*polyClient
+	for _, c := range clients {
+		if p.healthy(c, best) {
+			list = append(list, c)
+		} else {
+			down = append(down, c)
+		}
+	}
+	return append(list, down...)
+}
+
+// do runs f on each client in turn until it succeeds, submissions stop at the
+// first node that answered since its error comes from the chain, not the node
+func (p *PolyPool) do(method string, submit bool, f func(c *polyClient) error) (err error) {
+	for _, c := range p.ordered() {
+		start := time.Now()
+		err = f(c)
+		c.record(method, start, err)
+		if err == nil || (submit && !isPolyNodeError(err)) {
+			return
+		}
+		Log.Warnf("poly %s on %s failed: %v", method, c.url, err)
+	}
+	return
+}
+
+// isPolyNodeError tells transport failures apart from errors returned by the chain
+func isPolyNodeError(err error) bool {
+	var postErr client.PostErr
+	if errors.As(err, &postErr) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "read rpc response body error") ||
+		strings.Contains(msg, "json.Unmarshal JsonRpcResponse")
+}
+
+func (p *PolyPool) GetCurrentBlockHeight() (height uint32, err error) {
+	err = p.do("getblockcount", false, func(c *polyClient) (err error) {
+		height, err = c.PolySdk.GetCurrentBlockHeight()
+		if err == nil {
+			c.mu.Lock()
+			c.height = height
+			c.mu.Unlock()
+		}
+		return
+	})
+	return
+}
+
+func (p *PolyPool) GetHeaderByHeight(height uint32) (hdr *types.Header, err error) {
+	err = p.do("getheader", false, func(c *polyClient) (err error) {
+		hdr, err = c.PolySdk.GetHeaderByHeight(height)
+		return
+	})
+	return
+}
+
+func (p *PolyPool) GetSmartContractEventByBlock(height uint32) (events []*sdkcom.SmartContactEvent, err error) {
+	err = p.do("getsmartcodeeventbyblock", false, func(c *polyClient) (err error) {
+		events, err = c.PolySdk.GetSmartContractEventByBlock(height)
+		return
+	})
+	return
+}
+
+// GetSmartContractEvent returns nil while the tx is not executed
+func (p *PolyPool) GetSmartContractEvent(txHash string) (event *sdkcom.SmartContactEvent, err error) {
+	err = p.do("getsmartcodeevent", false, func(c *polyClient) (err error) {
+		event, err = c.PolySdk.GetSmartContractEvent(txHash)
+		return
+	})
+	return
+}
+
+func (p *PolyPool) GetCrossStatesProof(height uint32, key string) (proof *sdkcom.MerkleProof, err error) {
+	err = p.do("getcrossstatesproof", false, func(c *polyClient) (err error) {
+		proof, err = c.PolySdk.GetCrossStatesProof(height, key)
+		return
+	})
+	return
+}
+
+func (p *PolyPool) GetStorage(contractAddress string, key []byte) (value []byte, err error) {
+	err = p.do("getstorage", false, func(c *polyClient) (err error) {
+		value, err = c.PolySdk.GetStorage(contractAddress, key)
+		return
+	})
+	return
+}
+
+func (p *PolyPool) ImportOuterTransfer(sourceChainId uint64, txData []byte, height uint32, proof []byte,
+	relayerAddress []byte, HeaderOrCrossChainMsg []byte, signer signer.Signer) (hash pCommon.Uint256, err error) {
+	err = p.do("importoutertransfer", true, func(c *polyClient) (err error) {
+		tx, err := c.Native.Ccm.NewImportOuterTransferTransaction(sourceChainId, txData, height, proof, relayerAddress, HeaderOrCrossChainMsg)
+		if err != nil {
+			return
+		}
+		hash, err = c.sendTx(tx, signer)
+		return
+	})
+	return
+}
+
+func (p *PolyPool) AddSignature(sideChainId uint64, subject, sig []byte, signer signer.Signer) (hash pCommon.Uint256, err error) {
+	err = p.do("addsignature", true, func(c *polyClient) (err error) {
+		tx, err := c.Native.Sm.NewAddSignatureTransaction(signer.Address(), sideChainId, subject, sig)
+		if err != nil {
+			return
+		}
+		hash, err = c.sendTx(tx, signer)
+		return
+	})
+	return
+}
+
+func (c *polyClient) sendTx(tx *types.Transaction, signer signer.Signer) (pCommon.Uint256, error) {
+	if err := c.SignToTransaction(tx, signer); err != nil {
+		return pCommon.UINT256_EMPTY, err
+	}
+	return c.SendTransaction(tx)
+}
+
+// probe refreshes height and health of every client
+func (p *PolyPool) probe() {
+	var wg sync.WaitGroup
+	for _, c := range p.list() {
+		c := c
+		GoFunc(&wg, func() {
+			start := time.Now()
+			height, err := c.PolySdk.GetCurrentBlockHeight()
+			c.record("getblockcount", start, err)
+			if err == nil {
+				c.mu.Lock()
+				c.height = height
+				c.mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	best := p.bestHeight()
+	for _, c := range p.list() {
+		up := 0.0
+		if p.healthy(c, best) {
+			up = 1
+		}
+		metrics.PolyRpcHealthy.WithLabelValues(c.url).Set(up)
+	}
+}
+
+func (v *Voter) probePolyPool() {
+	for {
+		v.poly.probe()
+		if !sleep(v.ctx, PolyPoolProbeInterval) {
+			return
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package voter
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/polynetwork/neo3-voter/db"
+	"github.com/polynetwork/neo3-voter/metrics"
+	common1 "github.com/polynetwork/poly/common"
+	"github.com/polynetwork/poly/core/types"
+	"github.com/polynetwork/poly/merkle"
+	common2 "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+)
+
+// polyMakeProof is a makeProof value for neo, proven against the cross state
+// root of the header after its block
+type polyMakeProof struct {
+	key    string
+	txHash string
+	value  []byte
+	param  *common2.ToMerkleValue
+}
+
+// polyBlock holds what a poly block asks the voter to sign, or why it could
+// not be scanned. hdr is the header at height+1 and still has to be verified
+// before anything is signed.
+type polyBlock struct {
+	height uint32
+	hdr    *types.Header
+	proofs []polyMakeProof
+	err    error
+}
+
+// prefetchPoly scans the blocks in [from, end) ahead of the signing cursor,
+// with more workers the further behind
+func (v *Voter) prefetchPoly(ctx context.Context, from, end uint32) <-chan interface{} {
+	workers := fetchWorkers(end-from, v.config.PolyConfig.FetchWorkers)
+	metrics.FetchWorkers.WithLabelValues(metrics.ChainPoly).Set(float64(workers))
+	return prefetch(ctx, from, end, workers, func(height uint32) interface{} {
+		block, err := v.scanPolyBlock(height)
+		if err != nil {
+			return &polyBlock{height: height, err: err}
+		}
+		return block
+	})
+}
+
+// scanPolyBlock only reads, the keeper check of the header is left to the
+// signing cursor which sees the config blocks in order
+func (v *Voter) scanPolyBlock(height uint32) (*polyBlock, error) {
+	hdr, err := v.poly.GetHeaderByHeight(height + 1)
+	if err != nil {
+		return nil, err
+	}
+	events, err := v.poly.GetSmartContractEventByBlock(height)
+	if err != nil {
+		return nil, err
+	}
+
+	block := &polyBlock{height: height, hdr: hdr}
+	for _, event := range events {
+		for _, notify := range event.Notify {
+			if notify.ContractAddress != v.config.PolyConfig.EntranceContractAddress {
+				continue
+			}
+			states := notify.States.([]interface{})
+			method, _ := states[0].(string)
+			if method != "makeProof" {
+				continue
+			}
+			if uint64(states[2].(float64)) != v.config.NeoConfig.SideChainId {
+				continue
+			}
+			key := states[5].(string)
+			if record := v.bdb.GetPolyEvent(db.PolyEventKey(key)); record != nil && record.Status == db.EventConfirmed {
+				Log.Infof("makeProof key %s already confirmed, skip", key)
+				continue
+			}
+			if record := v.bdb.GetQuarantine(key); record != nil && !record.Approved {
+				Log.Infof("makeProof key %s is quarantined, skip", key)
+				continue
+			}
+			proof, err := v.poly.GetCrossStatesProof(hdr.Height-1, key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get proof for key %s: %v", key, err)
+			}
+			auditpath, err := hex.DecodeString(proof.AuditPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode audit path of key %s: %v", key, err)
+			}
+			// the value is only trusted once it hashes up to the signed cross state root
+			value, err := merkle.MerkleProve(auditpath, hdr.CrossStateRoot[:])
+			if err != nil {
+				return nil, fmt.Errorf("audit path of key %s does not match header %d: %v", key, hdr.Height, err)
+			}
+			param := &common2.ToMerkleValue{}
+			if err = param.Deserialization(common1.NewZeroCopySource(value)); err != nil {
+				return nil, fmt.Errorf("failed to deserialize MakeTxParam (value: %x, err: %v)", value, err)
+			}
+			block.proofs = append(block.proofs, polyMakeProof{key: key, txHash: event.TxHash, value: value, param: param})
+		}
+	}
+	return block, nil
+}
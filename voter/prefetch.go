@@ -0,0 +1,53 @@
+package voter
+
+import (
+	"context"
+)
+
+// blocksPerWorker is how far behind the cursor has to be for each extra
+// fetch worker
+const blocksPerWorker = 16
+
+// fetchWorkers grows with the backlog up to max, one when caught up
+func fetchWorkers(behind uint32, max uint64) int {
+	n := uint64(behind/blocksPerWorker) + 1
+	if n > max {
+		n = max
+	}
+	return int(n)
+}
+
+// prefetch calls fetch for every height in [from, end) with up to workers
+// calls in flight and hands the results out in height order. The channel
+// closes after end or once ctx is done.
+func prefetch(ctx context.Context, from, end uint32, workers int, fetch func(height uint32) interface{}) <-chan interface{} {
+	slots := make(chan chan interface{}, workers-1)
+	go func() {
+		defer close(slots)
+		for height := from; height < end; height++ {
+			slot := make(chan interface{}, 1)
+			select {
+			case slots <- slot:
+			case <-ctx.Done():
+				return
+			}
+			go func(height uint32) {
+				slot <- fetch(height)
+			}(height)
+		}
+	}()
+
+	results := make(chan interface{})
+	go func() {
+		defer close(results)
+		for slot := range slots {
+			result := <-slot
+			select {
+			case results <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results
+}
@@ -0,0 +1,116 @@
+package voter
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchWorkers(t *testing.T) {
+	for _, tc := range []struct {
+		behind uint32
+		max    uint64
+		want   int
+	}{
+		{0, 8, 1},
+		{blocksPerWorker - 1, 8, 1},
+		{blocksPerWorker, 8, 2},
+		{blocksPerWorker * 3, 8, 4},
+		{blocksPerWorker * 100, 8, 8},
+		{blocksPerWorker * 100, 1, 1},
+	} {
+		if got := fetchWorkers(tc.behind, tc.max); got != tc.want {
+			t.Fatalf("fetchWorkers(%d, %d) = %d, want %d", tc.behind, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestPrefetchOrder(t *testing.T) {
+	const from, end = 100, 108
+	started := make(chan uint32, end-from)
+	release := make(map[uint32]chan struct{})
+	for h := uint32(from); h < end; h++ {
+		release[h] = make(chan struct{})
+	}
+	results := prefetch(context.Background(), from, end, end-from, func(height uint32) interface{} {
+		started <- height
+		<-release[height]
+		return height
+	})
+
+	// every fetch is in flight, let them complete last height first
+	for i := 0; i < end-from; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d fetches started", i, end-from)
+		}
+	}
+	for h := uint32(end - 1); h >= from; h-- {
+		close(release[h])
+	}
+
+	want := uint32(from)
+	for item := range results {
+		if item.(uint32) != want {
+			t.Fatalf("got height %d, want %d", item, want)
+		}
+		want++
+	}
+	if want != end {
+		t.Fatalf("results stopped at %d, want %d", want, end)
+	}
+}
+
+func TestPrefetchWorkers(t *testing.T) {
+	for _, workers := range []int{1, 2, 5} {
+		var inFlight, most int32
+		results := prefetch(context.Background(), 0, 40, workers, func(height uint32) interface{} {
+			n := atomic.AddInt32(&inFlight, 1)
+			for {
+				m := atomic.LoadInt32(&most)
+				if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+					break
+				}
+			}
+			// complete out of order
+			time.Sleep(time.Duration(height%3) * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			return height
+		})
+		count := 0
+		for item := range results {
+			if item.(uint32) != uint32(count) {
+				t.Fatalf("workers %d: got height %d, want %d", workers, item, count)
+			}
+			count++
+		}
+		if count != 40 {
+			t.Fatalf("workers %d: %d results", workers, count)
+		}
+		if most > int32(workers) {
+			t.Fatalf("workers %d: %d fetches in flight", workers, most)
+		}
+	}
+}
+
+func TestPrefetchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	results := prefetch(ctx, 0, 1000, 4, func(height uint32) interface{} {
+		return height
+	})
+	<-results
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		for range results {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("results not closed after cancel")
+	}
+}
@@ -0,0 +1,59 @@
+package voter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/joeqian10/neo3-gogogo/helper"
+	"github.com/joeqian10/neo3-gogogo/mpt"
+	pCommon "github.com/polynetwork/poly/common"
+	ccmCommon "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+)
+
+var errInvalidProof = errors.New("invalid neo state proof")
+
+// getCcmcId returns the storage id of the neo cross chain manager contract
+func (v *Voter) getCcmcId(c *neoClient) (int, error) {
+	if v.ccmcIdSet {
+		return v.ccmcId, nil
+	}
+	res := c.GetContractState(v.config.NeoConfig.CCMC)
+	if res.HasError() {
+		return 0, fmt.Errorf("neoSdk.GetContractState error: %s", res.GetErrorInfo())
+	}
+	v.ccmcId, v.ccmcIdSet = res.Result.Id, true
+	return v.ccmcId, nil
+}
+
+// verifyNeoProof checks locally what poly would check on ImportOuterTransfer:
+// the proof resolves against the witnessed state root, proves the storage key
+// of the lock event and carries a decodable cross chain tx
+func verifyNeoProof(rootHash string, contractId int, key, proof []byte) (*ccmCommon.MakeTxParam, error) {
+	root, err := helper.UInt256FromString(rootHash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: decode root hash %s: %v", errInvalidProof, rootHash, err)
+	}
+	id, k, proofs, err := mpt.ResolveProof(proof)
+	if err != nil {
+		return nil, fmt.Errorf("%w: resolve proof: %v", errInvalidProof, err)
+	}
+	if id != contractId {
+		return nil, fmt.Errorf("%w: proof is for contract id %d, expected %d", errInvalidProof, id, contractId)
+	}
+	if !bytes.Equal(k, key) {
+		return nil, fmt.Errorf("%w: proof is for key %x, expected %x", errInvalidProof, k, key)
+	}
+	value, err := mpt.VerifyProof(root, id, k, proofs)
+	if err != nil {
+		return nil, fmt.Errorf("%w: verify proof: %v", errInvalidProof, err)
+	}
+	param := new(ccmCommon.MakeTxParam)
+	if err := param.Deserialization(pCommon.NewZeroCopySource(value)); err != nil {
+		return nil, fmt.Errorf("%w: deserialize MakeTxParam %x: %v", errInvalidProof, value, err)
+	}
+	if len(param.TxHash) == 0 || len(param.ToContractAddress) == 0 || param.ToChainID == 0 || param.Method == "" {
+		return nil, fmt.Errorf("%w: incomplete MakeTxParam %x", errInvalidProof, value)
+	}
+	return param, nil
+}
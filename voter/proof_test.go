@@ -0,0 +1,96 @@
+package voter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joeqian10/neo3-gogogo/blockchain"
+	"github.com/joeqian10/neo3-gogogo/helper"
+	nio "github.com/joeqian10/neo3-gogogo/io"
+	"github.com/joeqian10/neo3-gogogo/mpt"
+	pCommon "github.com/polynetwork/poly/common"
+	ccmCommon "github.com/polynetwork/poly/native/service/cross_chain_manager/common"
+)
+
+const testCcmcId = -5
+
+// testNeoProof builds a state root and a proof of key in contract id holding
+// param, the way neo's getproof encodes it
+func testNeoProof(t *testing.T, id int, key []byte, param *ccmCommon.MakeTxParam) (string, []byte) {
+	sink := pCommon.NewZeroCopySink(nil)
+	param.Serialization(sink)
+	item, err := nio.ToArray(&blockchain.StorageItem{Value: sink.Bytes()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storageKey, err := nio.ToArray(&blockchain.StorageKey{Id: id, Key: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := mpt.NewLeafNode(item)
+	root := mpt.NewExtensionNode(mpt.ToNibbles(storageKey), mpt.NewHashNode(leaf.GetHash()))
+
+	w := nio.NewBufBinaryWriter()
+	w.WriteVarBytes(storageKey)
+	w.WriteVarUInt(2)
+	w.WriteVarBytes(root.ToArrayWithoutReference())
+	w.WriteVarBytes(leaf.ToArrayWithoutReference())
+	return root.GetHash().String(), w.Bytes()
+}
+
+func testMakeTxParam() *ccmCommon.MakeTxParam {
+	return &ccmCommon.MakeTxParam{
+		TxHash:              []byte{1, 2, 3, 4},
+		CrossChainID:        []byte{5, 6, 7, 8},
+		FromContractAddress: helper.HexToBytes("e14fdd69cf7bf6afb9265ac806e09fea438df7b8"),
+		ToChainID:           2,
+		ToContractAddress:   helper.HexToBytes("25820465d41a57dca24529e88387ac2d78722778"),
+		Method:              "unlock",
+		Args:                []byte{9, 9, 9},
+	}
+}
+
+func TestVerifyNeoProof(t *testing.T) {
+	key := []byte{0x01, 0x02, 0x05, 0x00}
+	rootHash, proof := testNeoProof(t, testCcmcId, key, testMakeTxParam())
+	otherRoot, _ := testNeoProof(t, testCcmcId, key, &ccmCommon.MakeTxParam{
+		TxHash: []byte{1}, ToContractAddress: []byte{1}, ToChainID: 2, Method: "unlock",
+	})
+	tampered := append([]byte{}, proof...)
+	tampered[len(tampered)-1] ^= 0xff
+	incomplete := testMakeTxParam()
+	incomplete.Method = ""
+	incompleteRoot, incompleteProof := testNeoProof(t, testCcmcId, key, incomplete)
+
+	for _, tc := range []struct {
+		name     string
+		rootHash string
+		id       int
+		key      []byte
+		proof    []byte
+		ok       bool
+	}{
+		{"valid", rootHash, testCcmcId, key, proof, true},
+		{"tampered proof", rootHash, testCcmcId, key, tampered, false},
+		{"wrong state root", otherRoot, testCcmcId, key, proof, false},
+		{"bad root hash", "0x1234", testCcmcId, key, proof, false},
+		{"other contract", rootHash, testCcmcId + 1, key, proof, false},
+		{"other key", rootHash, testCcmcId, []byte{0x01, 0x02, 0x05, 0x01}, proof, false},
+		{"truncated proof", rootHash, testCcmcId, key, proof[:3], false},
+		{"incomplete param", incompleteRoot, testCcmcId, key, incompleteProof, false},
+	} {
+		param, err := verifyNeoProof(tc.rootHash, tc.id, tc.key, tc.proof)
+		if !tc.ok {
+			if !errors.Is(err, errInvalidProof) {
+				t.Fatalf("%s: got %v, want %v", tc.name, err, errInvalidProof)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if param.Method != "unlock" || string(param.CrossChainID) != string([]byte{5, 6, 7, 8}) || param.ToChainID != 2 {
+			t.Fatalf("%s: unexpected param %+v", tc.name, param)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package voter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/polynetwork/neo3-voter/config"
+)
+
+// live holds the settings a reload may change while the monitors run
+type live struct {
+	n2pContract  string
+	neoInterval  time.Duration
+	polyInterval time.Duration
+}
+
+func liveOf(conf *config.Config) live {
+	return live{
+		n2pContract:  conf.NeoConfig.N2PContract,
+		neoInterval:  time.Duration(conf.NeoPollInterval) * time.Second,
+		polyInterval: time.Duration(conf.PolyPollInterval) * time.Second,
+	}
+}
+
+func (v *Voter) settings() live {
+	v.liveMu.RLock()
+	defer v.liveMu.RUnlock()
+	return v.live
+}
+
+type configField struct {
+	name string
+	get  func(*config.Config) interface{}
+}
+
+// identityFields decide what the voter signs and as whom, they never reload
+var identityFields = []configField{
+	{"NeoConfig.SideChainId", func(c *config.Config) interface{} { return c.NeoConfig.SideChainId }},
+	{"NeoConfig.CCMC", func(c *config.Config) interface{} { return c.NeoConfig.CCMC }},
+	{"NeoConfig.WalletFile", func(c *config.Config) interface{} { return c.NeoConfig.WalletFile }},
+	{"NeoConfig.SignerPublicKeys", func(c *config.Config) interface{} { return c.NeoConfig.SignerPublicKeys }},
+	{"PolyConfig.EntranceContractAddress", func(c *config.Config) interface{} { return c.PolyConfig.EntranceContractAddress }},
+	{"PolyConfig.WalletFile", func(c *config.Config) interface{} { return c.PolyConfig.WalletFile }},
+	{"PolyConfig.WalletAccount", func(c *config.Config) interface{} { return c.PolyConfig.WalletAccount }},
+	{"PolyConfig.RemoteSigner", func(c *config.Config) interface{} { return c.PolyConfig.RemoteSigner }},
+	{"PolyConfig.Pkcs11", func(c *config.Config) interface{} { return c.PolyConfig.Pkcs11 }},
+	{"PolyConfig.TrustedKeepers", func(c *config.Config) interface{} { return c.PolyConfig.TrustedKeepers }},
+	{"PolyConfig.TrustedConfigHeight", func(c *config.Config) interface{} { return c.PolyConfig.TrustedConfigHeight }},
+	{"BoltDbPath", func(c *config.Config) interface{} { return c.BoltDbPath }},
+}
+
+// restartFields are read once at start, a reload leaves them as they were
+var restartFields = []configField{
+	{"NeoConfig.FetchWorkers", func(c *config.Config) interface{} { return c.NeoConfig.FetchWorkers }},
+	{"PolyConfig.FetchWorkers", func(c *config.Config) interface{} { return c.PolyConfig.FetchWorkers }},
+	{"PolyConfig.MaxInFlight", func(c *config.Config) interface{} { return c.PolyConfig.MaxInFlight }},
+	{"PolyConfig.TxTimeout", func(c *config.Config) interface{} { return c.PolyConfig.TxTimeout }},
+	{"PolyConfig.MaxResubmits", func(c *config.Config) interface{} { return c.PolyConfig.MaxResubmits }},
+	{"NeoConfig.WsUrlList", func(c *config.Config) interface{} { return c.NeoConfig.WsUrlList }},
+	{"AuditLogFile", func(c *config.Config) interface{} { return c.AuditLogFile }},
+	{"SignPolicy", func(c *config.Config) interface{} { return c.SignPolicy }},
+	{"ForceConfig", func(c *config.Config) interface{} { return c.ForceConfig }},
+	{"HttpAddr", func(c *config.Config) interface{} { return c.HttpAddr }},
+	{"ShutdownTimeout", func(c *config.Config) interface{} { return c.ShutdownTimeout }},
+	{"HealthWindow", func(c *config.Config) interface{} { return c.HealthWindow }},
+	{"ReplayInterval", func(c *config.Config) interface{} { return c.ReplayInterval }},
+	{"Log", func(c *config.Config) interface{} { l := c.Log; l.Level = ""; return l }},
+}
+
+func changedFields(old, new *config.Config, fields []configField) (changed []string) {
+	for _, f := range fields {
+		if !reflect.DeepEqual(f.get(old), f.get(new)) {
+			changed = append(changed, f.name)
+		}
+	}
+	return
+}
+
+// Reload applies the rpc endpoints, N2PContract and poll intervals of conf
+// while running, it refuses the whole config if an identity field changed
+func (v *Voter) Reload(conf *config.Config) error {
+	if changed := changedFields(v.config, conf, identityFields); len(changed) > 0 {
+		return fmt.Errorf("%s changed, restart the voter to apply", strings.Join(changed, ", "))
+	}
+	if len(conf.NeoConfig.RpcUrlList) == 0 || len(conf.PolyConfig.RpcUrlList) == 0 {
+		return fmt.Errorf("rpc url lists must not be empty")
+	}
+	if ignored := changedFields(v.config, conf, restartFields); len(ignored) > 0 {
+		Log.Warnf("reload leaves %s as it was until restart", strings.Join(ignored, ", "))
+	}
+
+	v.neo.reset(conf.NeoConfig.RpcUrlList)
+	v.poly.reset(conf.PolyConfig.RpcUrlList)
+	v.neo.probe()
+	v.poly.probe()
+
+	v.liveMu.Lock()
+	v.live = liveOf(conf)
+	v.liveMu.Unlock()
+
+	Log.Infof("reloaded neo rpc %v, poly rpc %v, N2PContract %q, poll intervals neo %ds poly %ds",
+		conf.NeoConfig.RpcUrlList, conf.PolyConfig.RpcUrlList, conf.NeoConfig.N2PContract,
+		conf.NeoPollInterval, conf.PolyPollInterval)
+	return nil
+}
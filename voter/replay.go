@@ -0,0 +1,100 @@
+package voter
+
+import (
+	"sort"
+	"time"
+
+	"github.com/polynetwork/neo3-voter/db"
+)
+
+// failedHeights groups failed events by the block they came from, oldest first
+func failedHeights(records map[string]*db.EventRecord) (heights []uint32, keys map[uint32]map[string]bool) {
+	keys = make(map[uint32]map[string]bool)
+	for key, record := range records {
+		if record.Height == 0 {
+			Log.Warnf("failed event %s has no height and can not be replayed", key)
+			continue
+		}
+		if keys[record.Height] == nil {
+			keys[record.Height] = make(map[string]bool)
+			heights = append(heights, record.Height)
+		}
+		keys[record.Height][key] = true
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return
+}
+
+func (v *Voter) replayDue(last *time.Time) bool {
+	if time.Since(*last) < time.Duration(v.config.ReplayInterval)*time.Second {
+		return false
+	}
+	*last = time.Now()
+	return true
+}
+
+// replayNeoEvents votes failed lock events again, monitorNeo runs it while
+// idle at the tip so the checkpoint never has to wait for them
+func (v *Voter) replayNeoEvents() {
+	records, err := v.bdb.FailedNeoEvents()
+	if err != nil {
+		Log.Errorf("FailedNeoEvents failed:%v", err)
+		return
+	}
+	heights, keys := failedHeights(records)
+	for _, height := range heights {
+		if v.ctx.Err() != nil {
+			return
+		}
+		block := v.fetchNeoBlockFrom(v.chooseClient(), height)
+		if block.err != nil {
+			Log.Warnf("replay neo height %d: %v", height, block.err)
+			return
+		}
+		events := block.events[:0]
+		for _, ev := range block.events {
+			if keys[height][string(db.NeoEventKey(ev.txHash, ev.index))] {
+				events = append(events, ev)
+			}
+		}
+		block.events = events
+		Log.Infof("replay %d failed neo events of height %d", len(events), height)
+		if err = v.voteNeoBlock(block); err != nil {
+			Log.Warnf("replay neo height %d: %v", height, err)
+			return
+		}
+	}
+}
+
+// replayPolyEvents signs failed makeProof events again, monitorPoly runs it
+// while idle at the tip
+func (v *Voter) replayPolyEvents() {
+	records, err := v.bdb.FailedPolyEvents()
+	if err != nil {
+		Log.Errorf("FailedPolyEvents failed:%v", err)
+		return
+	}
+	heights, keys := failedHeights(records)
+	for _, height := range heights {
+		if v.ctx.Err() != nil {
+			return
+		}
+		block, err := v.scanPolyBlock(height)
+		if err != nil {
+			Log.Warnf("replay poly height %d: %v", height, err)
+			return
+		}
+		proofs := block.proofs[:0]
+		for _, mp := range block.proofs {
+			if keys[height][string(db.PolyEventKey(mp.key))] {
+				proofs = append(proofs, mp)
+			}
+		}
+		block.proofs = proofs
+		Log.Infof("replay %d failed makeProof events of poly height %d", len(proofs), height)
+		if err = v.handleMakeTxEvents(block); err != nil {
+			Log.Warnf("replay poly height %d: %v", height, err)
+			return
+		}
+	}
+}
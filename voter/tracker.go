@@ -0,0 +1,219 @@
+package voter
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/polynetwork/neo3-voter/audit"
+	"github.com/polynetwork/neo3-voter/db"
+	"github.com/polynetwork/neo3-voter/log"
+	"github.com/polynetwork/neo3-voter/metrics"
+	sdkcom "github.com/polynetwork/poly-go-sdk/common"
+)
+
+// trackPolyTxs confirms the txs in the PolyTx bucket in the background so the
+// monitors never wait for poly, a failed or timed out tx is sent again up to
+// MaxResubmits times
+func (v *Voter) trackPolyTxs() {
+	for sleep(v.ctx, time.Second) {
+		records, err := v.bdb.ListPolyTx()
+		if err != nil {
+			Log.Errorf("ListPolyTx failed:%v", err)
+			continue
+		}
+		for txHash, record := range records {
+			if v.ctx.Err() != nil {
+				return
+			}
+			v.checkPolyTx(txHash, record)
+		}
+	}
+}
+
+func (v *Voter) checkPolyTx(txHash string, record *db.PolyTxRecord) {
+	txLog := Log.WithFields(log.Fields{"chain": metrics.ChainPoly, "height": record.Height, "polyTx": txHash, "kind": record.Kind, "phase": "wait"})
+	event, err := v.poly.GetSmartContractEvent(txHash)
+	switch {
+	case err != nil:
+		txLog.Warnf("GetSmartContractEvent of %s failed:%v", txHash, err)
+	case event == nil:
+		timeout := time.Duration(v.config.PolyConfig.TxTimeout) * time.Second
+		if time.Since(time.Unix(record.SentAt, 0)) > timeout {
+			v.resubmitPolyTx(txHash, record, fmt.Errorf("not executed after %s", timeout))
+		}
+	case event.State == 1:
+		txLog.Infof("poly tx %s confirmed: %s", txHash, polyTxOutcome(record.Kind, event))
+		v.finishPolyTx(txHash, record, db.EventConfirmed, nil)
+	default:
+		v.resubmitPolyTx(txHash, record, fmt.Errorf("execution failed, notify: %v", polyTxNotify(event)))
+	}
+}
+
+// polyTxOutcome says what a successful tx did
+func polyTxOutcome(kind string, event *sdkcom.SmartContactEvent) string {
+	if kind == db.PolyTxVote {
+		return "vote accepted"
+	}
+	for _, notify := range event.Notify {
+		if states, ok := notify.States.([]interface{}); ok && len(states) > 0 && states[0] == "AddSignatureQuorum" {
+			return "signature added, quorum reached"
+		}
+	}
+	return "signature added"
+}
+
+func polyTxNotify(event *sdkcom.SmartContactEvent) []interface{} {
+	states := make([]interface{}, 0, len(event.Notify))
+	for _, notify := range event.Notify {
+		states = append(states, notify.States)
+	}
+	return states
+}
+
+// resubmitPolyTx sends the tx of record again, or marks its event failed for replay
+// after MaxResubmits or once poly rejects it for good. Poly is asked first
+// whether an earlier tx or the other voters already got it done, waiting for
+// poly or neo to catch up costs no attempt.
+func (v *Voter) resubmitPolyTx(txHash string, record *db.PolyTxRecord, reason error) {
+	txLog := Log.WithFields(log.Fields{"chain": metrics.ChainPoly, "height": record.Height, "polyTx": txHash, "kind": record.Kind, "phase": "resubmit"})
+	if v.settlePolyTx(txHash, record, v.polyTxStatus(record), txLog) {
+		return
+	}
+	if uint64(record.Attempts) > v.config.PolyConfig.MaxResubmits {
+		txLog.Errorf("poly tx %s of %s %s failed after %d attempts, replayed later: %v", txHash, record.Kind, record.EventKey, record.Attempts, reason)
+		v.finishPolyTx(txHash, record, db.EventFailed, reason)
+		return
+	}
+	txLog.Warnf("poly tx %s of %s %s: %v, sending again", txHash, record.Kind, record.EventKey, reason)
+
+	var newHash string
+	var err error
+	switch record.Kind {
+	case db.PolyTxVote:
+		var height uint32
+		var crossChainId []byte
+		if height, err = v.voteHeight(record.Height); err == nil {
+			if newHash, crossChainId, err = v.commitVote(v.chooseClient(), record.NeoTxHash, record.StorageKey, height); err == nil {
+				record.CrossChainId = hex.EncodeToString(crossChainId)
+			}
+		}
+	case db.PolyTxSignature:
+		var subject, sig []byte
+		if subject, err = hex.DecodeString(record.Subject); err == nil {
+			if sig, err = hex.DecodeString(record.Signature); err == nil {
+				signed := &audit.Record{Seq: record.AuditSeq, Hash: record.AuditHash}
+				newHash, err = v.commitSig(record.Height, subject, sig, signed)
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown kind %q", record.Kind)
+	}
+	if v.settlePolyTx(txHash, record, err, txLog) {
+		return
+	}
+	if err != nil {
+		// the old hash stays tracked and is tried again after TxTimeout
+		observePolyError(record.Kind, err)
+		txLog.Errorf("resubmit poly tx %s failed:%v", txHash, err)
+		record.Attempts++
+		record.SentAt = time.Now().Unix()
+		if err = v.bdb.PutPolyTx(txHash, record); err != nil {
+			txLog.Errorf("PutPolyTx failed:%v", err)
+		}
+		return
+	}
+	record.Attempts++
+	record.SentAt = time.Now().Unix()
+	metrics.IncSubmission(record.Kind, metrics.ResultSubmitted)
+	if err = v.bdb.ReplacePolyTx(txHash, newHash, record); err != nil {
+		txLog.Errorf("ReplacePolyTx failed:%v", err)
+		return
+	}
+	v.putTrackedEvent(record, db.EventSubmitted, newHash)
+}
+
+// polyTxStatus asks poly whether the tx of record is already done or can no
+// longer succeed, a failed tx leaves no error text in its event
+func (v *Voter) polyTxStatus(record *db.PolyTxRecord) error {
+	switch record.Kind {
+	case db.PolyTxVote:
+		return v.voteStatus(record.CrossChainId)
+	case db.PolyTxSignature:
+		subject, err := hex.DecodeString(record.Subject)
+		if err != nil {
+			return err
+		}
+		return v.signatureStatus(subject)
+	default:
+		return fmt.Errorf("unknown kind %q", record.Kind)
+	}
+}
+
+// settlePolyTx handles the classes of err that end or delay a resubmit, it
+// reports whether the tx must not be sent now
+func (v *Voter) settlePolyTx(txHash string, record *db.PolyTxRecord, err error, txLog *log.Entry) bool {
+	switch {
+	case settled(err):
+		// poly has it from an earlier attempt or from the other voters
+		txLog.Infof("poly tx %s: %v", txHash, err)
+		v.finishPolyTx(txHash, record, db.EventConfirmed, nil)
+	case hopeless(err):
+		observePolyError(record.Kind, err)
+		txLog.Errorf("poly tx %s of %s %s failed, replayed later: %v", txHash, record.Kind, record.EventKey, err)
+		v.finishPolyTx(txHash, record, db.EventFailed, err)
+	case transient(err):
+		// tried again on the next round
+		observePolyError(record.Kind, err)
+		txLog.Warnf("resubmit poly tx %s delayed:%v", txHash, err)
+	default:
+		return false
+	}
+	return true
+}
+
+// finishPolyTx stops tracking a tx and settles its event, the next tracker
+// pass tries again if the db refuses
+func (v *Voter) finishPolyTx(txHash string, record *db.PolyTxRecord, status db.EventStatus, err error) {
+	if dbErr := v.bdb.FinishPolyTx(txHash, record, status); dbErr != nil {
+		Log.Errorf("FinishPolyTx %s failed:%v", txHash, dbErr)
+		return
+	}
+	if status == db.EventConfirmed {
+		metrics.IncSubmission(record.Kind, metrics.ResultSucceeded)
+	} else {
+		metrics.IncSubmission(record.Kind, metrics.ResultFailed)
+	}
+	metrics.ObserveWaitTx(time.Unix(record.FirstSentAt, 0), err)
+}
+
+func (v *Voter) putTrackedEvent(record *db.PolyTxRecord, status db.EventStatus, txHash string) {
+	if record.Kind == db.PolyTxVote {
+		v.putNeoEvent([]byte(record.EventKey), record.Height, status, txHash)
+	} else {
+		v.putPolyEvent([]byte(record.EventKey), record.Height, status, txHash)
+	}
+}
+
+// trackPolyTx hands a sent tx to the tracker
+func (v *Voter) trackPolyTx(txHash string, record *db.PolyTxRecord) error {
+	now := time.Now().Unix()
+	record.Attempts, record.FirstSentAt, record.SentAt = 1, now, now
+	return v.bdb.PutPolyTx(txHash, record)
+}
+
+// tracked tells if the event of record is waiting in the tracker
+func (v *Voter) tracked(record *db.EventRecord) bool {
+	return record != nil && record.Status == db.EventSubmitted && v.bdb.GetPolyTx(record.PolyTxHash) != nil
+}
+
+// polyTxsInFlight counts the tracked txs of kind
+func (v *Voter) polyTxsInFlight(kind string) (n int, err error) {
+	records, err := v.bdb.ListPolyTx()
+	for _, record := range records {
+		if record.Kind == kind {
+			n++
+		}
+	}
+	return
+}
@@ -19,35 +19,11 @@
 package voter
 
 import (
-	"math/rand"
+	"context"
 	"sync"
 	"time"
-
-	"github.com/ontio/ontology-crypto/ec"
-	"github.com/ontio/ontology-crypto/keypair"
-	"github.com/polynetwork/poly/common"
 )
 
-func polyPrivateKey2Hex(pri keypair.PrivateKey) []byte {
-	switch t := pri.(type) {
-	case *ec.PrivateKey:
-		switch t.Algorithm {
-		case ec.ECDSA:
-
-		default:
-			panic("unsupported pk")
-		}
-		Nlen := (t.Params().BitSize + 7) >> 3
-		skBytes := t.D.Bytes()
-		skEncoded := make([]byte, Nlen)
-		// pad sk with zeroes
-		copy(skEncoded[Nlen-len(skBytes):], skBytes)
-		return skEncoded
-	default:
-		panic("unkown private key type")
-	}
-}
-
 // GoFunc runs a goroutine under WaitGroup
 func GoFunc(routinesGroup *sync.WaitGroup, f func()) {
 	routinesGroup.Add(1)
@@ -57,44 +33,14 @@ func GoFunc(routinesGroup *sync.WaitGroup, f func()) {
 	}()
 }
 
-func sleep() {
-	time.Sleep(time.Second)
-}
-
-func randIdx(size int) int {
-	return int(rand.Uint32()) % size
-}
-
-func parseAuditpath(path []byte) ([]byte, []byte, [][32]byte, error) {
-	source := common.NewZeroCopySource(path)
-	/*
-		l, eof := source.NextUint64()
-		if eof {
-			return nil, nil, nil, nil
-		}
-	*/
-	value, eof := source.NextVarBytes()
-	if eof {
-		return nil, nil, nil, nil
+// sleep waits for d, it returns false if ctx is done before that
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
 	}
-	size := int((source.Size() - source.Pos()) / common.UINT256_SIZE)
-	pos := make([]byte, 0)
-	hashs := make([][32]byte, 0)
-	for i := 0; i < size; i++ {
-		f, eof := source.NextByte()
-		if eof {
-			return nil, nil, nil, nil
-		}
-		pos = append(pos, f)
-
-		v, eof := source.NextHash()
-		if eof {
-			return nil, nil, nil, nil
-		}
-		var onehash [32]byte
-		copy(onehash[:], (v.ToArray())[0:32])
-		hashs = append(hashs, onehash)
-	}
-
-	return value, pos, hashs, nil
 }
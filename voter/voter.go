@@ -1,48 +1,64 @@
 package voter
 
 import (
+	"context"
 	"fmt"
-	"github.com/joeqian10/neo3-gogogo/keys"
-	"github.com/joeqian10/neo3-gogogo/rpc"
+	"github.com/polynetwork/neo3-voter/audit"
 	"github.com/polynetwork/neo3-voter/config"
 	"github.com/polynetwork/neo3-voter/db"
 	"github.com/polynetwork/neo3-voter/log"
-	sdk "github.com/polynetwork/poly-go-sdk"
-	"github.com/polynetwork/poly/core/types"
+	"github.com/polynetwork/neo3-voter/policy"
+	"github.com/polynetwork/neo3-voter/signer"
+	"sync"
 	"time"
 )
 
 var Log = log.Log
 
 type Voter struct {
-	polySdk *sdk.PolySdk
-	signer  *sdk.Account
-	config  *config.Config
-	clients []*rpc.RpcClient
-	idx     int
-	pair    *keys.KeyPair
+	poly   *PolyPool
+	signer signer.Signer
+	config *config.Config
+	neo    *neoPool
+	stream *neoStream
+	policy *policy.Policy
 
+	voteMu             sync.Mutex
 	neoStateRootHeight uint32
+	ccmcId             int
+	ccmcIdSet          bool
+	polyKeepers        *polyKeepers
 
-	bdb *db.BoltDB
+	liveMu sync.RWMutex
+	live   live
+
+	bdb   *db.BoltDB
+	audit *audit.Log
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	neoBeat  int64
+	polyBeat int64
 }
 
-func New(polySdk *sdk.PolySdk, signer *sdk.Account, conf *config.Config) *Voter {
-	return &Voter{polySdk: polySdk, signer: signer, config: conf}
+func New(poly *PolyPool, signer signer.Signer, conf *config.Config) *Voter {
+	return &Voter{poly: poly, signer: signer, config: conf, live: liveOf(conf)}
 }
 
 func (v *Voter) init() (err error) {
-	// use poly's private key and neo's hash and curve
-	pkBytes := polyPrivateKey2Hex(v.signer.PrivateKey)
-	pair, err := keys.NewKeyPair(pkBytes)
+	v.policy, err = policy.New(&v.config.SignPolicy)
 	if err != nil {
 		return
 	}
-	v.pair = pair
+	if err = v.checkNeoSigner(); err != nil {
+		return
+	}
 	// fill neo clients
-	for _, url := range v.config.NeoConfig.RpcUrlList {
-		c := rpc.NewClient(url)
-		v.clients = append(v.clients, c)
+	v.neo = newNeoPool(v.config.NeoConfig.RpcUrlList)
+	if len(v.config.NeoConfig.WsUrlList) > 0 {
+		v.stream = newNeoStream(v.config.NeoConfig.WsUrlList, v.config.NeoConfig.CCMC)
 	}
 	v.neoStateRootHeight = 0
 	// add db
@@ -51,33 +67,61 @@ func (v *Voter) init() (err error) {
 		return
 	}
 	v.bdb = bdb
-
+	v.loadPolyKeepers()
+	v.audit, err = audit.Open(v.config.AuditLogFile)
+	if err != nil {
+		bdb.Close()
+		err = fmt.Errorf("audit log %s: %v", v.config.AuditLogFile, err)
+		return
+	}
+	if v.audit.Dropped > 0 {
+		Log.Warnf("audit log %s: cut off %d bytes of a partial last record", v.config.AuditLogFile, v.audit.Dropped)
+	}
 	return
 }
 
-func (v *Voter) Start() {
-	err := v.init()
-	if err != nil {
-		Log.Fatalf("Voter.init failed: %v", err)
+// Start opens what the voter needs and runs the monitors, nothing runs when
+// it returns an error
+func (v *Voter) Start(ctx context.Context) error {
+	if err := v.init(); err != nil {
+		return fmt.Errorf("Voter.init failed: %v", err)
 	}
 
-	go v.monitorNeo()
-	go v.monitorPoly()
+	v.ctx, v.cancel = context.WithCancel(ctx)
+	v.beatNeo()
+	v.beatPoly()
+	v.neo.probe()
+	v.poly.probe()
+	GoFunc(&v.wg, v.probeNeoPool)
+	GoFunc(&v.wg, v.probePolyPool)
+	if v.stream != nil {
+		GoFunc(&v.wg, func() { v.stream.run(v.ctx) })
+	}
+	GoFunc(&v.wg, v.trackPolyTxs)
+	GoFunc(&v.wg, v.monitorNeo)
+	GoFunc(&v.wg, v.monitorPoly)
+	return nil
 }
 
-func (v *Voter) waitTx(txHash string) (err error) {
-	start := time.Now()
-	var tx *types.Transaction
-	for {
-		tx, err = v.polySdk.GetTransaction(txHash)
-		if tx == nil || err != nil {
-			if time.Since(start) > time.Minute*5 {
-				err = fmt.Errorf("waitTx timeout")
-				return
-			}
-			time.Sleep(time.Second)
-			continue
-		}
+// Stop cancels the monitors, waits for them to checkpoint and closes the db.
+// After ShutdownTimeout it returns with db and audit log left open, as the
+// monitors may still write to them until the process exits.
+func (v *Voter) Stop() {
+	v.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		v.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		Log.Infof("voter monitors stopped")
+	case <-time.After(time.Duration(v.config.ShutdownTimeout) * time.Second):
+		Log.Warnf("voter monitors did not stop in %d seconds, exit without closing db and audit log", v.config.ShutdownTimeout)
 		return
 	}
+
+	v.bdb.Close()
+	v.audit.Close()
 }